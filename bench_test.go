@@ -0,0 +1,92 @@
+package exceltesting
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fc-shota-miyazaki/go-exceltesting/testonly"
+)
+
+// benchRowCounts はデータ投入のベンチマークの行数です
+var benchRowCounts = []int{100, 1000, 10000}
+
+func benchCompanyTable(n int) *table {
+	t := &table{
+		sheet:   "会社",
+		name:    "company",
+		columns: []string{"company_cd", "company_name", "founded_year", "created_at", "updated_at", "revision"},
+	}
+	for i := 0; i < n; i++ {
+		t.data = append(t.data, []string{fmt.Sprintf("%05d", i), fmt.Sprintf("company %d", i), "1989", "current_timestamp", "current_timestamp", "1"})
+	}
+	return t
+}
+
+func Benchmark_table_buildInsertSQL(b *testing.B) {
+	for _, n := range benchRowCounts {
+		t := benchCompanyTable(n)
+		b.Run(fmt.Sprintf("rows=%d", n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				_ = t.buildInsertSQL()
+			}
+		})
+	}
+}
+
+func Benchmark_exceltesing_Load(b *testing.B) {
+	conn := testonly.OpenTestDB(b)
+	b.Cleanup(func() { conn.Close() })
+
+	testonly.ExecSQLFile(b, conn, filepath.Join("testdata", "schema", "ddl.sql"))
+
+	for _, n := range benchRowCounts {
+		path := writeTestBook(b, filepath.Join(b.TempDir(), "load.xlsx"), []*table{benchCompanyTable(n)})
+		b.Run(fmt.Sprintf("rows=%d", n), func(b *testing.B) {
+			e := New(conn)
+			b.ResetTimer()
+			start := time.Now()
+			for i := 0; i < b.N; i++ {
+				e.Load(b, LoadRequest{TargetBookPath: path})
+			}
+			reportRowsPerSec(b, n, time.Since(start))
+		})
+	}
+}
+
+func BenchmarkLoadRaw(b *testing.B) {
+	conn := testonly.OpenTestDB(b)
+	b.Cleanup(func() { conn.Close() })
+
+	testonly.ExecSQLFile(b, conn, filepath.Join("testdata", "schema", "ddl.sql"))
+
+	for _, n := range benchRowCounts {
+		t := benchCompanyTable(n)
+		r := LoadRawRequest{TableName: t.name, Columns: t.columns, Values: t.data}
+		b.Run(fmt.Sprintf("rows=%d", n), func(b *testing.B) {
+			ctx := context.Background()
+			start := time.Now()
+			for i := 0; i < b.N; i++ {
+				tx, err := conn.BeginTx(ctx, nil)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if err := LoadRawWithContext(ctx, tx, r); err != nil {
+					b.Fatal(err)
+				}
+				_ = tx.Rollback()
+			}
+			reportRowsPerSec(b, n, time.Since(start))
+		})
+	}
+}
+
+// reportRowsPerSec は1秒あたりの投入行数をベンチマークの結果に追加します
+func reportRowsPerSec(b *testing.B, n int, elapsed time.Duration) {
+	if elapsed > 0 {
+		b.ReportMetric(float64(n*b.N)/elapsed.Seconds(), "rows/s")
+	}
+}
@@ -0,0 +1,427 @@
+package exceltesting
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+	"golang.org/x/exp/slices"
+)
+
+// sqlColumn はSQLシートのステートメントを比較する際のカラム名です
+const sqlColumn = "sql"
+
+// BookDiff はExcelのBook同士の差分です
+type BookDiff struct {
+	// Sheets はシートごとの差分です。差分のないシートは含みません
+	Sheets []SheetDiff `json:"sheets"`
+}
+
+// SheetDiff はシート単位の差分です。行やセルの差分は、変更前のBookを期待値とみなした比較結果です
+type SheetDiff struct {
+	Sheet string `json:"sheet"`
+	// Added, Removed はシートが追加、削除された場合に true です
+	Added   bool `json:"added,omitempty"`
+	Removed bool `json:"removed,omitempty"`
+	// Headers はテーブル名やバージョンなど、カラム定義行より上のヘッダの変更です
+	Headers        []HeaderDiff `json:"headers,omitempty"`
+	AddedColumns   []string     `json:"addedColumns,omitempty"`
+	RemovedColumns []string     `json:"removedColumns,omitempty"`
+	// RemovedRows, AddedRows は変更前、変更後のBookにのみ存在する行です。Row はそれぞれのBookのA列の番号です
+	RemovedRows []Row `json:"removedRows,omitempty"`
+	AddedRows   []Row `json:"addedRows,omitempty"`
+	// ChangedCells は値が変更されたセルです。Row は変更前のBookのA列の番号、Want は変更前、Got は変更後の値です
+	ChangedCells []CellDiff `json:"changedCells,omitempty"`
+	// Err はシートの読み込みに失敗した場合のエラーです
+	Err error `json:"-"`
+}
+
+// HeaderDiff はヘッダの項目の変更です
+type HeaderDiff struct {
+	Name string `json:"name"`
+	Old  string `json:"old"`
+	New  string `json:"new"`
+}
+
+// bookSheet はBookを比較、テキストに出力するために読み込んだシートです
+type bookSheet struct {
+	name    string
+	version string
+	// headers はヘッダの項目名と値の組です
+	headers [][2]string
+	// sql はSQLシートの場合に true です
+	sql bool
+	// raw は説明や表紙など、テーブルとして読み込めないシートの場合に true です。t はセルの値をそのまま読み込みます
+	raw bool
+	t   *table
+	err error
+}
+
+// DiffBooks はExcelのBook oldPath と newPath を比較し、シート、行、セル単位の差分を返します。
+//
+// 行は先頭のカラムから順に、値が一意になるまでのカラムをキーとして突き合わせます。
+// 一意にならない場合は行の順序を無視してすべてのカラムの値で、SQLシートはステートメントの順序で突き合わせます。
+func DiffBooks(oldPath, newPath string) (*BookDiff, error) {
+	olds, err := readBook(oldPath)
+	if err != nil {
+		return nil, fmt.Errorf("exceltesting: %s: %w", oldPath, err)
+	}
+	news, err := readBook(newPath)
+	if err != nil {
+		return nil, fmt.Errorf("exceltesting: %s: %w", newPath, err)
+	}
+
+	d := &BookDiff{}
+	for _, o := range olds {
+		i := slices.IndexFunc(news, func(n bookSheet) bool { return n.name == o.name })
+		if i < 0 {
+			d.Sheets = append(d.Sheets, SheetDiff{Sheet: o.name, Removed: true})
+			continue
+		}
+		if s := diffSheets(o, news[i]); !s.Equal() {
+			d.Sheets = append(d.Sheets, s)
+		}
+	}
+	for _, n := range news {
+		if slices.IndexFunc(olds, func(o bookSheet) bool { return o.name == n.name }) < 0 {
+			d.Sheets = append(d.Sheets, SheetDiff{Sheet: n.name, Added: true})
+		}
+	}
+	return d, nil
+}
+
+// Equal は差分がない場合に true を返します
+func (d *BookDiff) Equal() bool {
+	return len(d.Sheets) == 0
+}
+
+// JSON は差分をJSON形式で出力します
+func (d *BookDiff) JSON() ([]byte, error) {
+	return json.MarshalIndent(d, "", "  ")
+}
+
+func (d *BookDiff) String() string {
+	var b strings.Builder
+	for _, s := range d.Sheets {
+		b.WriteString(s.String())
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// Equal は差分がない場合に true を返します
+func (s *SheetDiff) Equal() bool {
+	return s.Err == nil && !s.Added && !s.Removed && len(s.Headers) == 0 &&
+		len(s.AddedColumns) == 0 && len(s.RemovedColumns) == 0 &&
+		len(s.RemovedRows) == 0 && len(s.AddedRows) == 0 && len(s.ChangedCells) == 0
+}
+
+func (s *SheetDiff) String() string {
+	switch {
+	case s.Err != nil:
+		return fmt.Sprintf("sheet %s: %v", s.Sheet, s.Err)
+	case s.Added:
+		return fmt.Sprintf("sheet %s: added", s.Sheet)
+	case s.Removed:
+		return fmt.Sprintf("sheet %s: removed", s.Sheet)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "sheet %s:", s.Sheet)
+	for _, h := range s.Headers {
+		fmt.Fprintf(&b, "\n  %s: %s -> %s", h.Name, displayValue(h.Old), displayValue(h.New))
+	}
+	for _, c := range s.RemovedColumns {
+		fmt.Fprintf(&b, "\n  - column %s", c)
+	}
+	for _, c := range s.AddedColumns {
+		fmt.Fprintf(&b, "\n  + column %s", c)
+	}
+	for _, r := range s.RemovedRows {
+		fmt.Fprintf(&b, "\n  - row %d: %s", r.Row, formatCells(r.Cells))
+	}
+	for _, r := range s.AddedRows {
+		fmt.Fprintf(&b, "\n  + row %d: %s", r.Row, formatCells(r.Cells))
+	}
+	for _, c := range s.ChangedCells {
+		fmt.Fprintf(&b, "\n  ~ row %d: %s: %s -> %s", c.Row, c.Column, displayValue(c.Want), displayValue(c.Got))
+	}
+	return b.String()
+}
+
+// MarshalJSON はエラーを文字列として出力します
+func (s SheetDiff) MarshalJSON() ([]byte, error) {
+	type alias SheetDiff
+	v := struct {
+		alias
+		Error string `json:"error,omitempty"`
+	}{alias: alias(s)}
+	if s.Err != nil {
+		v.Error = s.Err.Error()
+	}
+	return json.Marshal(v)
+}
+
+// diffSheets は同じ名前のシート o と n を比較します。
+// 両方のBookでテーブルとして読み込めないシートは、セルの値を行の順序で比較します。
+func diffSheets(o, n bookSheet) SheetDiff {
+	d := SheetDiff{Sheet: o.name}
+	raw := o.raw && n.raw
+	if !raw && (o.err != nil || n.err != nil) {
+		if o.err != nil {
+			d.Err = o.err
+		} else {
+			d.Err = n.err
+		}
+		return d
+	}
+
+	for _, h := range o.headers {
+		v := headerValue(n.headers, h[0])
+		if h[1] != v {
+			d.Headers = append(d.Headers, HeaderDiff{Name: h[0], Old: h[1], New: v})
+		}
+	}
+	for _, h := range n.headers {
+		if headerValue(o.headers, h[0]) == "" && h[1] != "" {
+			d.Headers = append(d.Headers, HeaderDiff{Name: h[0], New: h[1]})
+		}
+	}
+
+	var common []string
+	for _, c := range o.t.columns {
+		if slices.Contains(n.t.columns, c) {
+			common = append(common, c)
+		} else {
+			d.RemovedColumns = append(d.RemovedColumns, c)
+		}
+	}
+	for _, c := range n.t.columns {
+		if !slices.Contains(o.t.columns, c) {
+			d.AddedColumns = append(d.AddedColumns, c)
+		}
+	}
+
+	want, got := sheetRows(o.t, common), sheetRows(n.t, common)
+	var mt matching
+	if (o.sql && n.sql) || raw {
+		mt.orderBy = common
+	} else {
+		mt.keys = leadingKey(want, got, len(common))
+	}
+	td, err := mt.diff(want, got)
+	if err != nil {
+		d.Err = err
+		return d
+	}
+	d.RemovedRows = td.MissingRows
+	d.AddedRows = td.ExtraRows
+	d.ChangedCells = td.ChangedCells
+	return d
+}
+
+func headerValue(headers [][2]string, name string) string {
+	for _, h := range headers {
+		if h[0] == name {
+			return h[1]
+		}
+	}
+	return ""
+}
+
+// sheetRows はシートの行をカラム columns の値とA列の番号を持つ行に変換します
+func sheetRows(t *table, columns []string) []compareRow {
+	rows := make([]compareRow, 0, len(t.data))
+	for i, row := range t.data {
+		r := compareRow{num: t.rowNum(i)}
+		for _, c := range columns {
+			r.values = append(r.values, x{column: c, value: row[slices.Index(t.columns, c)]})
+		}
+		rows = append(rows, r)
+	}
+	return rows
+}
+
+// leadingKey は先頭から順にカラムを加え、両方のシートで値が一意になる最小のカラムを返します。
+// すべてのカラムでも一意にならない場合は nil を返します。
+func leadingKey(a, b []compareRow, n int) []string {
+	for k := 1; k <= n; k++ {
+		idx := make([]int, k)
+		for i := range idx {
+			idx[i] = i
+		}
+		if uniqueRows(a, idx) && uniqueRows(b, idx) {
+			var keys []string
+			for _, i := range idx {
+				if len(a) > 0 {
+					keys = append(keys, a[0].values[i].column)
+				} else if len(b) > 0 {
+					keys = append(keys, b[0].values[i].column)
+				}
+			}
+			return keys
+		}
+	}
+	return nil
+}
+
+func uniqueRows(rows []compareRow, idx []int) bool {
+	seen := make(map[string]bool, len(rows))
+	for _, r := range rows {
+		k := rowKey(r, idx)
+		if seen[k] {
+			return false
+		}
+		seen[k] = true
+	}
+	return true
+}
+
+// readBook はBookの各シートを読み込みます。SQLシートはステートメントを sql カラムの行として読み込みます
+func readBook(path string) ([]bookSheet, error) {
+	f, err := excelize.OpenFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("open excel file: %w", err)
+	}
+	defer f.Close()
+
+	var sheets []bookSheet
+	for _, sheet := range f.GetSheetList() {
+		s := bookSheet{name: sheet, version: extractSheetFormatVersion(f, sheet)}
+		if isSQLSheet(f, sheet, "") {
+			stmts, err := loadSQLSheet(f, sheet)
+			s.sql = true
+			s.err = err
+			s.t = &table{sheet: sheet, columns: []string{sqlColumn}}
+			for _, stmt := range stmts {
+				s.t.data = append(s.t.data, []string{stmt})
+			}
+			sheets = append(sheets, s)
+			continue
+		}
+
+		t, err := (&exceltesing{}).loadExcelSheet(f, sheet)
+		if err != nil {
+			s.err = fmt.Errorf("load excel sheet: %w", err)
+			if rt, rerr := readRawSheet(f, sheet); rerr == nil {
+				s.raw = true
+				s.t = rt
+			}
+			sheets = append(sheets, s)
+			continue
+		}
+		s.t = t
+		s.headers = [][2]string{
+			{"table", t.name},
+			{"query", t.query},
+			{"version", s.version},
+			{beforeHookMarker, strings.Join(t.before, "; ")},
+			{afterHookMarker, strings.Join(t.after, "; ")},
+			{whereMarker, t.where},
+		}
+		sheets = append(sheets, s)
+	}
+	return sheets, nil
+}
+
+// readRawSheet はシートの空でない行のセルの値を、列名をカラム名、行番号をA列の番号として読み込みます
+func readRawSheet(f *excelize.File, sheet string) (*table, error) {
+	rows, err := f.GetRows(sheet)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &table{sheet: sheet}
+	width := 0
+	for i, row := range rows {
+		if strings.Join(row, "") == "" {
+			continue
+		}
+		if len(row) > width {
+			width = len(row)
+		}
+		t.data = append(t.data, row)
+		t.rowNums = append(t.rowNums, i+1)
+	}
+	for i := 1; i <= width; i++ {
+		name, err := excelize.ColumnNumberToName(i)
+		if err != nil {
+			return nil, err
+		}
+		t.columns = append(t.columns, name)
+	}
+	for i, row := range t.data {
+		t.data[i] = append(row, make([]string, width-len(row))...)
+	}
+	return t, nil
+}
+
+// WriteBookText はBookの各シートを、シート名、ヘッダ、カラム、行の順に安定したテキスト形式で w に出力します。
+// 行はA列の番号とカラムの値をタブ区切りで出力します。git の textconv での利用を想定しています。
+func WriteBookText(w io.Writer, path string) error {
+	sheets, err := readBook(path)
+	if err != nil {
+		return fmt.Errorf("exceltesting: %s: %w", path, err)
+	}
+
+	for i, s := range sheets {
+		if i > 0 {
+			if _, err := fmt.Fprintln(w); err != nil {
+				return err
+			}
+		}
+		if err := writeSheetText(w, s); err != nil {
+			return fmt.Errorf("exceltesting: sheet = %s: %w", s.name, err)
+		}
+	}
+	return nil
+}
+
+func writeSheetText(w io.Writer, s bookSheet) error {
+	if _, err := fmt.Fprintf(w, "# sheet: %s\n", s.name); err != nil {
+		return err
+	}
+	if s.err != nil {
+		if _, err := fmt.Fprintf(w, "# error: %v\n", s.err); err != nil || !s.raw {
+			return err
+		}
+	}
+	if s.sql {
+		// SQLシートはステートメントを1行ずつ出力する
+		if _, err := fmt.Fprintln(w, "# sql"); err != nil {
+			return err
+		}
+		for _, row := range s.t.data {
+			if _, err := fmt.Fprintln(w, strings.ReplaceAll(row[0], "\n", " ")); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for _, h := range s.headers {
+		if h[1] == "" {
+			continue
+		}
+		if _, err := fmt.Fprintf(w, "# %s: %s\n", strings.TrimPrefix(h[0], "#"), strings.ReplaceAll(h[1], "\n", " ")); err != nil {
+			return err
+		}
+	}
+
+	cw := csv.NewWriter(w)
+	cw.Comma = '\t'
+	if err := cw.Write(append([]string{"no"}, s.t.columns...)); err != nil {
+		return err
+	}
+	for i, row := range s.t.data {
+		if err := cw.Write(append([]string{strconv.Itoa(s.t.rowNum(i))}, row...)); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,190 @@
+package exceltesting
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/xuri/excelize/v2"
+)
+
+func TestDiffBooks(t *testing.T) {
+	dir := t.TempDir()
+	oldPath := writeTestBook(t, filepath.Join(dir, "old.xlsx"), []*table{
+		{
+			sheet:   "会社",
+			name:    "company",
+			columns: []string{"company_cd", "company_name", "founded_year"},
+			data:    [][]string{{"00001", "Future", "1989"}, {"00002", "YDC", "1972"}},
+		},
+		{sheet: "削除", name: "removed", columns: []string{"id"}},
+	})
+	newPath := writeTestBook(t, filepath.Join(dir, "new.xlsx"), []*table{
+		{
+			sheet:   "会社",
+			name:    "company2",
+			columns: []string{"company_cd", "company_name", "founded_year", "revision"},
+			data:    [][]string{{"00001", "Future", "1990", "1"}, {"00003", "FutureOne", "2002", "1"}},
+		},
+		{sheet: "追加", name: "added", columns: []string{"id"}},
+	})
+
+	got, err := DiffBooks(oldPath, newPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `sheet 会社:
+  table: company -> company2
+  + column revision
+  - row 2: company_cd=00002, company_name=YDC, founded_year=1972
+  + row 2: company_cd=00003, company_name=FutureOne, founded_year=2002
+  ~ row 1: founded_year: 1989 -> 1990
+sheet 削除: removed
+sheet 追加: added
+`
+	if diff := cmp.Diff(want, got.String()); diff != "" {
+		t.Errorf("DiffBooks() mismatch (-want +got):\n%s", diff)
+	}
+
+	same, err := DiffBooks(oldPath, oldPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !same.Equal() {
+		t.Errorf("DiffBooks() should be equal for the same book: %s", same)
+	}
+}
+
+func TestDiffBooks_NotTableSheet(t *testing.T) {
+	dir := t.TempDir()
+	writeNotes := func(name string, notes [][]any) string {
+		path := writeTestBook(t, filepath.Join(dir, name), []*table{{sheet: "会社", name: "company", columns: []string{"company_cd"}}})
+		f, err := excelize.OpenFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		f.NewSheet("説明")
+		for i, row := range notes {
+			cell, _ := excelize.CoordinatesToCellName(1, i+1)
+			if err := f.SetSheetRow("説明", cell, &row); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := f.Save(); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	oldPath := writeNotes("old.xlsx", [][]any{{"テストデータの説明"}, {}, {"作成者", "Future"}})
+	newPath := writeNotes("new.xlsx", [][]any{{"テストデータの説明"}, {}, {"作成者", "YDC"}, {"更新日", "2024-01-01"}})
+
+	same, err := DiffBooks(oldPath, oldPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !same.Equal() {
+		t.Errorf("DiffBooks() should be equal for the same book: %s", same)
+	}
+
+	got, err := DiffBooks(oldPath, newPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `sheet 説明:
+  + row 4: A=更新日, B=2024-01-01
+  ~ row 3: B: Future -> YDC
+`
+	if diff := cmp.Diff(want, got.String()); diff != "" {
+		t.Errorf("DiffBooks() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func Test_leadingKey(t *testing.T) {
+	rows := func(vs ...[]string) []compareRow {
+		var rs []compareRow
+		for _, v := range vs {
+			r := compareRow{}
+			for i, s := range v {
+				r.values = append(r.values, x{column: []string{"a", "b", "c"}[i], value: s})
+			}
+			rs = append(rs, r)
+		}
+		return rs
+	}
+	tests := []struct {
+		name string
+		a, b []compareRow
+		want []string
+	}{
+		{
+			name: "first column is unique",
+			a:    rows([]string{"1", "x", "p"}, []string{"2", "x", "p"}),
+			b:    rows([]string{"1", "y", "p"}),
+			want: []string{"a"},
+		},
+		{
+			name: "composite key",
+			a:    rows([]string{"1", "x", "p"}, []string{"1", "y", "p"}),
+			b:    rows([]string{"2", "x", "p"}),
+			want: []string{"a", "b"},
+		},
+		{
+			name: "not unique",
+			a:    rows([]string{"1", "x", "p"}, []string{"1", "x", "p"}),
+			b:    nil,
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := leadingKey(tt.a, tt.b, 3)
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("leadingKey() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestWriteBookText(t *testing.T) {
+	path := writeTestBook(t, filepath.Join(t.TempDir(), "book.xlsx"), []*table{
+		{
+			sheet:   "会社",
+			name:    "company",
+			columns: []string{"company_cd", "company_name"},
+			data:    [][]string{{"00001", "Future"}, {"00002", "Y\tDC"}},
+		},
+	})
+
+	var b bytes.Buffer
+	if err := WriteBookText(&b, path); err != nil {
+		t.Fatal(err)
+	}
+	want := "# sheet: 会社\n" +
+		"# table: company\n" +
+		"# version: 2.0\n" +
+		"no\tcompany_cd\tcompany_name\n" +
+		"1\t00001\tFuture\n" +
+		"2\t00002\t\"Y\tDC\"\n"
+	if diff := cmp.Diff(want, b.String()); diff != "" {
+		t.Errorf("WriteBookText() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func writeTestBook(t testing.TB, path string, tables []*table) string {
+	t.Helper()
+	f := excelize.NewFile()
+	defer f.Close()
+	for _, tb := range tables {
+		if err := writeTableSheet(f, tb); err != nil {
+			t.Fatal(err)
+		}
+	}
+	f.DeleteSheet("Sheet1")
+	if err := f.SaveAs(path); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
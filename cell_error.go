@@ -0,0 +1,173 @@
+package exceltesting
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/jackc/pgconn"
+	"golang.org/x/exp/slices"
+)
+
+// mysqlColumnPattern はMySQLのエラーメッセージからカラム名を抽出します
+// (e.g. "Incorrect integer value: 'abc' for column 'founded_year' at row 1", "Column 'x' cannot be null")
+var mysqlColumnPattern = regexp.MustCompile(`(?i)(?:column|field) '([^']+)'`)
+
+// mysqlRowPattern はMySQLのエラーメッセージから、複数行のINSERTで失敗した行の番号 (1始まり) を抽出します
+// (e.g. "Incorrect integer value: 'abc' for column 'founded_year' at row 2")
+var mysqlRowPattern = regexp.MustCompile(`(?i) at row (\d+)`)
+
+// CellError はExcelのセルの値が原因でデータ投入に失敗したことを表すエラーです。
+//
+// 原因となったカラムが特定できない場合、Column と Value は空文字になります。
+type CellError struct {
+	// Sheet はシート名です
+	Sheet string
+	// Row はA列の番号です
+	Row int
+	// Column はカラム名です
+	Column string
+	// Value はセルの値です
+	Value string
+	// Err はデータベースドライバが返したエラーです
+	Err error
+}
+
+func (e *CellError) Error() string {
+	if e.Column == "" {
+		return fmt.Sprintf("sheet = %s, row = %d: %v", e.Sheet, e.Row, e.Err)
+	}
+	return fmt.Sprintf("sheet = %s, row = %d, column = %s, value = %q: %v", e.Sheet, e.Row, e.Column, e.Value, e.Err)
+}
+
+func (e *CellError) Unwrap() error {
+	return e.Err
+}
+
+// mysqlCellError はMySQLのエラーメッセージに含まれる行の番号とカラム名から *CellError を作成します。
+// MySQLの TRUNCATE は暗黙的にコミットし以降はセーブポイントを利用できないため、1行ずつ再投入せずにメッセージから特定します。
+// 行が特定できない場合は nil を返します。
+func mysqlCellError(t *table, err error) *CellError {
+	m := mysqlRowPattern.FindStringSubmatch(err.Error())
+	if m == nil {
+		return nil
+	}
+	i, _ := strconv.Atoi(m[1])
+	if i < 1 || len(t.data) < i {
+		return nil
+	}
+
+	ce := &CellError{Sheet: t.sheet, Row: t.rowNum(i - 1), Err: err}
+	if c := namedErrorColumn(err); slices.Contains(t.columns, c) {
+		ce.Column = c
+		ce.Value = t.data[i-1][slices.Index(t.columns, c)]
+	}
+	return ce
+}
+
+// locateCellError はセーブポイント内で1行ずつ再投入し、投入に失敗した行とカラムを特定します。
+// 行が特定できない場合は cause をそのまま返します。
+func (e *exceltesing) locateCellError(ctx context.Context, tx *sql.Tx, t *table, cause error) error {
+	for i := range t.data {
+		r := t.row(i)
+
+		if _, err := tx.ExecContext(ctx, "SAVEPOINT "+savepointRow); err != nil {
+			return cause
+		}
+		if _, err := tx.ExecContext(ctx, r.buildInsertSQL()); err != nil {
+			if _, rerr := tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+savepointRow); rerr != nil {
+				return cause
+			}
+			return e.newCellError(ctx, tx, r, err)
+		}
+		// 後続行の一意制約違反などを再現するため、成功した行は残しておく
+		if _, err := tx.ExecContext(ctx, "RELEASE SAVEPOINT "+savepointRow); err != nil {
+			return cause
+		}
+	}
+	return cause
+}
+
+// newCellError は1行のみを持つテーブル r の投入エラーから *CellError を作成します
+func (e *exceltesing) newCellError(ctx context.Context, tx *sql.Tx, r *table, err error) *CellError {
+	ce := &CellError{
+		Sheet: r.sheet,
+		Row:   r.rowNum(0),
+		Err:   err,
+	}
+
+	column := errorColumn(err, r)
+	if column == "" {
+		column = e.probeErrorColumn(ctx, tx, r)
+	}
+	ce.Column = column
+	if i := slices.Index(r.columns, column); i >= 0 {
+		ce.Value = r.data[0][i]
+	}
+	return ce
+}
+
+// errorColumn はドライバのエラー情報からエラーの原因となったカラム名を返します
+func errorColumn(err error, r *table) string {
+	if c := namedErrorColumn(err); c != "" {
+		return c
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Position > 0 {
+		if i := positionColumnIndex(r, int(pgErr.Position)); i >= 0 {
+			return r.columns[i]
+		}
+	}
+	return ""
+}
+
+// namedErrorColumn はエラーに明示されているカラム名を返します
+func namedErrorColumn(err error) string {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.ColumnName
+	}
+	if m := mysqlColumnPattern.FindStringSubmatch(err.Error()); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+// positionColumnIndex はINSERTステートメント中の位置(1始まりの文字数)がどのカラムの値を指しているかを返します
+func positionColumnIndex(r *table, pos int) int {
+	offset := utf8.RuneCountInString(r.insertSQLPrefix() + "(")
+	for i, exp := range rowSQLExps(r.data[0]) {
+		n := utf8.RuneCountInString(exp)
+		if offset < pos && pos <= offset+n {
+			return i
+		}
+		offset += n + len(", ")
+	}
+	return -1
+}
+
+// probeErrorColumn はカラムごとに値を投入してエラーの原因となったカラム名を推定します。
+// 他のカラムのNOT NULL制約など、別カラムが原因のエラーは無視します。
+func (e *exceltesing) probeErrorColumn(ctx context.Context, tx *sql.Tx, r *table) string {
+	for i, column := range r.columns {
+		if _, err := tx.ExecContext(ctx, "SAVEPOINT "+savepointRow); err != nil {
+			return ""
+		}
+		q := fmt.Sprintf("INSERT INTO %s (%s) VALUES(%s);", r.name, column, cellSQLExp(r.data[0][i]))
+		_, err := tx.ExecContext(ctx, q)
+		if _, rerr := tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+savepointRow); rerr != nil {
+			return ""
+		}
+		if err == nil {
+			continue
+		}
+		if c := namedErrorColumn(err); c == "" || c == column {
+			return column
+		}
+	}
+	return ""
+}
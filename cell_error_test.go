@@ -0,0 +1,134 @@
+package exceltesting
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/jackc/pgconn"
+)
+
+func Test_errorColumn(t *testing.T) {
+	r := &table{
+		name:    "company",
+		columns: []string{"company_cd", "company_name", "founded_year"},
+		data:    [][]string{{"00001", "フューチャー", "abc"}},
+	}
+
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{
+			name: "postgres column name",
+			err:  &pgconn.PgError{Code: "23502", ColumnName: "revision"},
+			want: "revision",
+		},
+		{
+			name: "postgres position points to a value",
+			// INSERT INTO company (company_cd,company_name,founded_year) VALUES('00001', 'フューチャー', 'abc');
+			err:  &pgconn.PgError{Code: "22P02", Position: 86},
+			want: "founded_year",
+		},
+		{
+			name: "postgres position points outside of values",
+			err:  &pgconn.PgError{Code: "22P02", Position: 1},
+			want: "",
+		},
+		{
+			name: "mysql incorrect value",
+			err:  errors.New("Error 1366 (HY000): Incorrect integer value: 'abc' for column 'founded_year' at row 1"),
+			want: "founded_year",
+		},
+		{
+			name: "mysql not null",
+			err:  errors.New("Error 1048 (23000): Column 'company_name' cannot be null"),
+			want: "company_name",
+		},
+		{
+			name: "unknown",
+			err:  errors.New("Error 1062 (23000): Duplicate entry '00001' for key 'company.PRIMARY'"),
+			want: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := errorColumn(tt.err, r); got != tt.want {
+				t.Errorf("errorColumn() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_mysqlCellError(t *testing.T) {
+	r := &table{
+		sheet:   "会社",
+		name:    "company",
+		columns: []string{"company_cd", "company_name", "founded_year"},
+		data:    [][]string{{"00001", "フューチャー", "1989"}, {"00002", "YDC", "abc"}},
+		rowNums: []int{1, 3},
+	}
+
+	tests := []struct {
+		name string
+		err  error
+		want *CellError
+	}{
+		{
+			name: "row and column",
+			err:  errors.New("Error 1366 (HY000): Incorrect integer value: 'abc' for column 'founded_year' at row 2"),
+			want: &CellError{Sheet: "会社", Row: 3, Column: "founded_year", Value: "abc"},
+		},
+		{
+			name: "row only",
+			err:  errors.New("Error 1265 (01000): Data truncated for column 'unknown' at row 1"),
+			want: &CellError{Sheet: "会社", Row: 1},
+		},
+		{
+			name: "row out of range",
+			err:  errors.New("Error 1366 (HY000): Incorrect integer value: 'abc' for column 'founded_year' at row 3"),
+			want: nil,
+		},
+		{
+			name: "no row",
+			err:  errors.New("Error 1062 (23000): Duplicate entry '00001' for key 'company.PRIMARY'"),
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := mysqlCellError(r, tt.err)
+			if diff := cmp.Diff(tt.want, got, cmpopts.IgnoreFields(CellError{}, "Err")); diff != "" {
+				t.Errorf("mysqlCellError() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestCellError_Error(t *testing.T) {
+	tests := []struct {
+		name string
+		err  *CellError
+		want string
+	}{
+		{
+			name: "with column",
+			err:  &CellError{Sheet: "会社", Row: 2, Column: "founded_year", Value: "abc", Err: errors.New("invalid input syntax")},
+			want: `sheet = 会社, row = 2, column = founded_year, value = "abc": invalid input syntax`,
+		},
+		{
+			name: "without column",
+			err:  &CellError{Sheet: "会社", Row: 2, Err: errors.New("duplicate key")},
+			want: `sheet = 会社, row = 2: duplicate key`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.err.Error(); got != tt.want {
+				t.Errorf("Error() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
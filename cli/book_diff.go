@@ -0,0 +1,43 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/fc-shota-miyazaki/go-exceltesting"
+)
+
+// DiffBooks はExcelのBook oldPath と newPath の差分を format の形式で w に出力します。
+// 差分がある場合はその旨のエラーを返します。
+func DiffBooks(oldPath, newPath, format string, w io.Writer) error {
+	d, err := exceltesting.DiffBooks(oldPath, newPath)
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case FormatJSON:
+		b, err := d.JSON()
+		if err != nil {
+			return fmt.Errorf("marshal json: %w", err)
+		}
+		if _, err := fmt.Fprintln(w, string(b)); err != nil {
+			return err
+		}
+	default:
+		if _, err := fmt.Fprint(w, d.String()); err != nil {
+			return err
+		}
+	}
+
+	if d.Equal() {
+		return nil
+	}
+	return errors.New("diff: books differ")
+}
+
+// Textconv はBookを git diff で比較できるテキスト形式で w に出力します
+func Textconv(path string, w io.Writer) error {
+	return exceltesting.WriteBookText(w, path)
+}
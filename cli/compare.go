@@ -3,7 +3,9 @@ package cli
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/signal"
 	"strings"
@@ -14,8 +16,22 @@ import (
 	_ "github.com/jackc/pgx/v4/stdlib"
 )
 
+// 比較結果の出力形式です
+const (
+	FormatText  = "text"
+	FormatJSON  = "json"
+	FormatJUnit = "junit"
+)
+
 func Compare(dbSource string, r exceltesting.CompareRequest) error {
+	return CompareWithFormat(dbSource, r, FormatText, os.Stdout)
+}
 
+// CompareWithFormat は比較結果を format の形式で w に出力します。
+// text 形式の場合は差分をエラーとして返し、それ以外の形式の場合は差分があればその旨のエラーを返します。
+// CompareRequest.Update を指定して期待値のBookを更新した場合は、更新したシートの差分をエラーとしません。
+// ただし、更新しないマッチャーのセルが一致しない場合はエラーとします。
+func CompareWithFormat(dbSource string, r exceltesting.CompareRequest, format string, w io.Writer) error {
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
 	defer stop()
 
@@ -29,12 +45,59 @@ func Compare(dbSource string, r exceltesting.CompareRequest) error {
 	}
 	e := exceltesting.New(db)
 
-	equal, errors := e.CompareWithContext(ctx, r)
-	if equal {
-		return nil
+	res, err := e.Diff(ctx, r)
+	if err != nil {
+		return err
 	}
 
-	return multiError{errs: errors}
+	switch format {
+	case FormatJSON:
+		b, err := res.JSON()
+		if err != nil {
+			return fmt.Errorf("marshal json: %w", err)
+		}
+		if _, err := fmt.Fprintln(w, string(b)); err != nil {
+			return err
+		}
+	case FormatJUnit:
+		if err := writeJUnit(w, res); err != nil {
+			return fmt.Errorf("write junit: %w", err)
+		}
+	}
+
+	if res.Equal() {
+		return nil
+	}
+	if res.Updated {
+		// 更新したシートの差分は表示のみとし、読み込みなどに失敗したシートのみエラーとする
+		// text 以外の形式は出力の updated で確認できるため、出力を壊さないよう text 形式のみ表示する
+		if format == FormatText {
+			_, _ = fmt.Fprintf(w, "updated %s\n", r.TargetBookPath)
+		}
+		var errs []error
+		for _, d := range res.Tables {
+			if len(d.NotUpdatedCells) > 0 {
+				errs = append(errs, fmt.Errorf("%s: %d matcher cells are not updated", d.Sheet, len(d.NotUpdatedCells)))
+			}
+			switch {
+			case d.Err != nil:
+				errs = append(errs, d.Err)
+			case !d.Equal() && format == FormatText:
+				_, _ = fmt.Fprintln(w, d.String())
+			}
+		}
+		if len(errs) == 0 {
+			return nil
+		}
+		if format != FormatText {
+			return errors.New("compare: failed")
+		}
+		return multiError{errs: errs}
+	}
+	if format != FormatText {
+		return errors.New("compare: mismatch")
+	}
+	return multiError{errs: res.Errors()}
 }
 
 type multiError struct {
@@ -0,0 +1,66 @@
+package cli
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+
+	"github.com/fc-shota-miyazaki/go-exceltesting"
+)
+
+// DiffDB は sourceDSN と targetDSN のデータベースのテーブルを比較し、差分を format の形式で w に出力します。
+// text 形式の場合は差分をエラーとして返し、json 形式の場合は差分があればその旨のエラーを返します。
+func DiffDB(sourceDSN, targetDSN string, r exceltesting.DiffDBRequest, format string, w io.Writer) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	source, err := openDB(sourceDSN)
+	if err != nil {
+		return fmt.Errorf("source: %w", err)
+	}
+	defer source.Close()
+	target, err := openDB(targetDSN)
+	if err != nil {
+		return fmt.Errorf("target: %w", err)
+	}
+	defer target.Close()
+
+	res, err := exceltesting.New(source).DiffDB(ctx, target, r)
+	if err != nil {
+		return err
+	}
+
+	if format == FormatJSON {
+		b, err := res.JSON()
+		if err != nil {
+			return fmt.Errorf("marshal json: %w", err)
+		}
+		if _, err := fmt.Fprintln(w, string(b)); err != nil {
+			return err
+		}
+	}
+
+	if res.Equal() {
+		return nil
+	}
+	if format != FormatText {
+		return errors.New("diff-db: mismatch")
+	}
+	return multiError{errs: res.Errors()}
+}
+
+func openDB(dbSource string) (*sql.DB, error) {
+	driver, dsn, err := normalizeDSN(dbSource)
+	if err != nil {
+		return nil, fmt.Errorf("dsn normalize: %w", err)
+	}
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("database open: %w", err)
+	}
+	return db, nil
+}
@@ -2,571 +2,133 @@ package cli
 
 import (
 	"context"
-	"database/sql"
-	"errors"
 	"fmt"
 	"os"
 	"os/signal"
 	"strings"
-	"time"
-	"unicode/utf8"
 
-	"github.com/jackc/pgtype"
-	"github.com/jackc/pgx/v4"
-	"github.com/jackc/pgx/v4/pgxpool"
-	"github.com/xuri/excelize/v2"
+	"github.com/fc-shota-miyazaki/go-exceltesting"
 	"golang.org/x/exp/slices"
+	"gopkg.in/yaml.v3"
 )
 
-const DefaultColumnCnt = 32
-
-var query = `SELECT tab.relname        AS table_name
-				 , tabdesc.description AS table_description
-				 , col.column_name
-				 , coldesc.description AS column_description
-				 , col.data_type
-				 , col.is_nullable
-				 , col.column_default
-			FROM pg_stat_user_tables tab
-					 LEFT OUTER JOIN pg_description tabdesc
-								ON tab.relid = tabdesc.objoid
-									AND tabdesc.objsubid = '0'
-					 LEFT OUTER JOIN information_schema.columns col
-								ON tab.relname = col.table_name
-									AND tab.schemaname = current_schema()
-					 LEFT OUTER JOIN pg_description coldesc
-								ON tab.relid = coldesc.objoid
-									AND col.ordinal_position = coldesc.objsubid
-			         LEFT OUTER JOIN pg_class pc
-                        ON tab.relid = pc.oid
-			WHERE exists(select 1 FROM tmp_exceltesting_dump_table_name WHERE tab.relname = name)
-				AND	tab.schemaname = current_schema()
-				AND col.table_schema = current_schema()
-				AND pc.relispartition = false
-			ORDER BY tab.relname
-				   , col.ordinal_position
-			;
-`
-
-var queryAll = `SELECT tab.relname        AS table_name
-				 , tabdesc.description AS table_description
-				 , col.column_name
-				 , coldesc.description AS column_description
-				 , col.data_type
-				 , col.is_nullable
-				 , col.column_default
-			FROM pg_stat_user_tables tab
-					 LEFT OUTER JOIN pg_description tabdesc
-								ON tab.relid = tabdesc.objoid
-									AND tabdesc.objsubid = '0'
-					 LEFT OUTER JOIN information_schema.columns col
-								ON tab.relname = col.table_name
-									AND tab.schemaname = current_schema()
-					 LEFT OUTER JOIN pg_description coldesc
-								ON tab.relid = coldesc.objoid
-									AND col.ordinal_position = coldesc.objsubid
-			         LEFT OUTER JOIN pg_class pc
-                        ON tab.relid = pc.oid
-			WHERE
-					tab.schemaname = current_schema()
-				AND col.table_schema = current_schema()
-			    AND pc.relispartition = false
-			ORDER BY tab.relname
-				   , col.ordinal_position
-			;
-`
-
-type TableDef struct {
-	Name    string
-	Comment string
-	Columns []ColumnDef
-}
-
-type ColumnDef struct {
-	Name         string
-	Comment      string
-	DataType     string
-	Constraint   string
-	DefaultValue string
+// dumpSpec はYAMLで記載するダンプの設定です
+//
+//	tables:
+//	  - name: orders
+//	    where: created_at > now() - interval '1 day'
+//	    orderBy: [created_at, id]
+//	queries:
+//	  - name: 注文ごとの明細数
+//	    query: SELECT order_id, count(*) FROM order_lines GROUP BY order_id
+type dumpSpec struct {
+	Tables []struct {
+		Name    string   `yaml:"name"`
+		Where   string   `yaml:"where"`
+		OrderBy []string `yaml:"orderBy"`
+	} `yaml:"tables"`
+	Queries []struct {
+		Name  string `yaml:"name"`
+		Query string `yaml:"query"`
+	} `yaml:"queries"`
 }
 
-func Dump(dbSource, targetFile, tableNameArg, systemColumnArg string, maxDumpSize int) error {
+// Dump はデータベースのテーブルの定義と行を opt.TargetBookPath に出力します。
+// specPath を指定した場合は、YAMLのダンプ設定を opt にマージします。テーブルごとの設定は opt を優先します。
+func Dump(dbSource, specPath string, opt exceltesting.DumpOptions) error {
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
 	defer stop()
 
-	var tableNames []string
-	if len(tableNameArg) > 0 {
-		tableNames = strings.Split(tableNameArg, ",")
-	}
-
-	var systemColumn []string
-	if len(systemColumnArg) > 0 {
-		systemColumn = strings.Split(systemColumnArg, ",")
-	}
-
-	driver, dsn, err := normalizeDSN(dbSource)
-	if err != nil {
-		return fmt.Errorf("dsn normalize: %w", err)
-	}
-	if driver == "mysql" {
-		return dumpMySQL(ctx, dsn, targetFile, tableNames, systemColumn, maxDumpSize)
-	}
-	return dumpPostgres(ctx, dbSource, targetFile, tableNames, systemColumn, maxDumpSize)
-}
-
-func dumpPostgres(ctx context.Context, dbSource, targetFile string, tableNames, systemColumn []string, maxDumpSize int) error {
-	conn, err := pgxpool.Connect(ctx, dbSource)
-	if err != nil {
-		return fmt.Errorf("pgxpool connect: %w", err)
-	}
-
-	if len(tableNames) > 0 {
-		_, err = conn.Exec(ctx, `CREATE TEMPORARY TABLE tmp_exceltesting_dump_table_name(name varchar(256))`)
+	if specPath != "" {
+		spec, err := readDumpSpec(specPath)
 		if err != nil {
-			return fmt.Errorf("cannot temporary table: %w", err)
-		}
-
-		batch := &pgx.Batch{}
-		for _, v := range tableNames {
-			batch.Queue("insert into tmp_exceltesting_dump_table_name values($1)", v)
-		}
-		if err := conn.SendBatch(ctx, batch).Close(); err != nil {
-			return fmt.Errorf("batch insert: %w", err)
+			return err
 		}
+		opt = mergeDumpSpec(spec, opt)
 	}
 
-	defs, err := selectTabColumnDef(ctx, conn, tableNames)
+	db, err := openDB(dbSource)
 	if err != nil {
 		return err
 	}
+	defer db.Close()
 
-	if len(defs) == 0 {
-		return errors.New("table not found")
-	}
-
-	f := excelize.NewFile()
-
-	var (
-		rowHeaderStyle, _ = f.NewStyle(&excelize.Style{
-			Border: []excelize.Border{
-				{Type: "top", Style: 1, Color: "000000"},
-				{Type: "left", Style: 1, Color: "000000"},
-				{Type: "right", Style: 1, Color: "000000"},
-				{Type: "bottom", Style: 1, Color: "000000"},
-			},
-			Fill: excelize.Fill{Type: "pattern", Color: []string{"#D9D9D9"}, Pattern: 1},
-		})
-
-		columnHeaderStyle, _ = f.NewStyle(&excelize.Style{
-			Border: []excelize.Border{
-				{Type: "top", Style: 1, Color: "#000000"},
-				{Type: "left", Style: 1, Color: "#000000"},
-				{Type: "right", Style: 1, Color: "#000000"},
-				{Type: "bottom", Style: 1, Color: "#000000"},
-			},
-			Fill: excelize.Fill{Type: "pattern", Color: []string{"#FCD5B4"}, Pattern: 1},
-		})
-
-		columnHeaderSystemStyle, _ = f.NewStyle(&excelize.Style{
-			Border: []excelize.Border{
-				{Type: "top", Style: 1, Color: "000000"},
-				{Type: "left", Style: 1, Color: "000000"},
-				{Type: "right", Style: 1, Color: "000000"},
-				{Type: "bottom", Style: 1, Color: "000000"},
-			},
-			Fill: excelize.Fill{Type: "pattern", Color: []string{"#BFBFBF"}, Pattern: 1},
-		})
-
-		rowStyle, _ = f.NewStyle(&excelize.Style{
-			Border: []excelize.Border{
-				{Type: "top", Style: 1, Color: "000000"},
-				{Type: "left", Style: 1, Color: "000000"},
-				{Type: "right", Style: 1, Color: "000000"},
-				{Type: "bottom", Style: 1, Color: "000000"},
-			},
-		})
-
-		// 値セルを文字列として扱うためのスタイル
-		valueTextStyle, _ = f.NewStyle(&excelize.Style{
-			NumFmt: 49, // Text
-			Border: []excelize.Border{
-				{Type: "top", Style: 1, Color: "000000"},
-				{Type: "left", Style: 1, Color: "000000"},
-				{Type: "right", Style: 1, Color: "000000"},
-				{Type: "bottom", Style: 1, Color: "000000"},
-			},
-		})
-	)
-
-	for i, tableDef := range defs {
-		sheetName := tableDef.Comment
-		if len(sheetName) == 0 {
-			sheetName = tableDef.Name
-		}
-
-		index := f.NewSheet(sheetName)
-
-		if i == 0 {
-			f.SetActiveSheet(index)
-		}
-
-		_ = f.SetCellValue(sheetName, "A1", tableDef.Comment)
-		_ = f.SetCellValue(sheetName, "A2", tableDef.Name)
-		_ = f.SetCellValue(sheetName, "A3", "version")
-		_ = f.SetCellValue(sheetName, "B3", "2.0")
-		_ = f.SetCellValue(sheetName, "A5", "項目名")
-		_ = f.SetCellValue(sheetName, "A6", "項目物理名")
-
-		_ = f.SetColWidth(sheetName, "A", "A", 12.86)
-		_ = f.SetCellStyle(sheetName, "A5", "A6", rowHeaderStyle)
-
-		for i, columnDef := range tableDef.Columns {
-			axisComment, _ := excelize.CoordinatesToCellName(2+i, 5)
-			axisName, _ := excelize.CoordinatesToCellName(2+i, 6)
-
-			_ = f.SetCellValue(sheetName, axisComment, columnDef.Comment)
-			_ = f.SetCellValue(sheetName, axisName, columnDef.Name)
-
-			currentCol, _ := excelize.ColumnNumberToName(2 + i)
-
-			width := utf8.RuneCountInString(columnDef.Comment) * 2
-			if width < utf8.RuneCountInString(columnDef.Name) {
-				width = utf8.RuneCountInString(columnDef.Name)
-			}
-			width += 2 //  + 2 for margin
-
-			_ = f.SetColWidth(sheetName, currentCol, currentCol, float64(width))
-
-			style := columnHeaderStyle
-			if slices.Contains(systemColumn, columnDef.Name) {
-				style = columnHeaderSystemStyle
-			}
-			_ = f.SetCellStyle(sheetName, axisComment, axisName, style)
-		}
-
-		records, err := selectExistsRecords(ctx, conn, tableDef, maxDumpSize)
-		if err != nil {
-			return fmt.Errorf("select exists records: %w", err)
-		}
-
-		// Add 3 empty row (only style, do not put numbers when no data)
-		vCell, _ := excelize.CoordinatesToCellName(1+len(tableDef.Columns), 12)
-		_ = f.SetCellStyle(sheetName, "A7", vCell, rowStyle)
-
-		// データレコードがあれば上書き
-		if len(records) > 0 {
-
-			// 枠線などのスタイルを設定
-			vCell, _ := excelize.CoordinatesToCellName(len(records[0])+1, len(records)+9) // 9 is data record start position
-			_ = f.SetCellStyle(sheetName, "A10", vCell, rowStyle)
-
-			for i, record := range records {
-				rowNum := 7 + i
-
-				vCell, _ := excelize.CoordinatesToCellName(1, rowNum)
-				_ = f.SetCellValue(sheetName, vCell, fmt.Sprint(i+1))
-
-				for j, cell := range record {
-					colNum := j + 2
-					vCell, _ := excelize.CoordinatesToCellName(colNum, rowNum)
-					_ = f.SetCellValue(sheetName, vCell, fmtCell(cell))
-					_ = f.SetCellStyle(sheetName, vCell, vCell, valueTextStyle)
-				}
-			}
-		}
-	}
-
-	f.DeleteSheet("Sheet1")
-
-	if err := f.SaveAs(targetFile); err != nil {
-		return fmt.Errorf("dump result save: %w", err)
+	if err := exceltesting.Dump(ctx, db, opt); err != nil {
+		return fmt.Errorf("dump: %w", err)
 	}
-
 	return nil
 }
 
-// MySQL対応: dumpMySQLはinformation_schemaからカラム情報を取得し、SELECTで実データを取得する
-func dumpMySQL(ctx context.Context, dsn, targetFile string, tableNames, systemColumn []string, maxDumpSize int) error {
-	db, err := sql.Open("mysql", dsn)
+func readDumpSpec(path string) (dumpSpec, error) {
+	var spec dumpSpec
+	b, err := os.ReadFile(path)
 	if err != nil {
-		return fmt.Errorf("mysql open: %w", err)
+		return spec, fmt.Errorf("read dump spec: %w", err)
 	}
-	defer db.Close()
-
-	// 対象テーブルの決定
-	if len(tableNames) == 0 {
-		rows, err := db.QueryContext(ctx, `SELECT table_name FROM information_schema.tables WHERE table_schema = DATABASE() ORDER BY table_name`)
-		if err != nil {
-			return fmt.Errorf("list tables: %w", err)
-		}
-		defer rows.Close()
-		for rows.Next() {
-			var name string
-			if err := rows.Scan(&name); err != nil {
-				return err
-			}
-			tableNames = append(tableNames, name)
-		}
-		if err := rows.Err(); err != nil {
-			return err
-		}
+	if err := yaml.Unmarshal(b, &spec); err != nil {
+		return spec, fmt.Errorf("parse dump spec %s: %w", path, err)
 	}
-
-	defs := make([]TableDef, 0, len(tableNames))
-	for _, tn := range tableNames {
-		// テーブルコメント
-		var tableComment string
-		_ = db.QueryRowContext(ctx, `SELECT table_comment FROM information_schema.tables WHERE table_schema = DATABASE() AND table_name = ?`, tn).Scan(&tableComment)
-
-		// カラム
-		cols := []ColumnDef{}
-		rows, err := db.QueryContext(ctx, `SELECT column_name, IFNULL(column_comment, ''), data_type, is_nullable, IFNULL(column_default, '') FROM information_schema.columns WHERE table_schema = DATABASE() AND table_name = ? ORDER BY ordinal_position`, tn)
-		if err != nil {
-			return fmt.Errorf("list columns: %w", err)
-		}
-		for rows.Next() {
-			var (
-				colName, colComment, dataType, isNullable, defaultVal string
-			)
-			if err := rows.Scan(&colName, &colComment, &dataType, &isNullable, &defaultVal); err != nil {
-				rows.Close()
-				return err
-			}
-			constraint := ""
-			if strings.EqualFold(isNullable, "NO") {
-				constraint = "NOT NULL"
-			}
-			cols = append(cols, ColumnDef{
-				Name:         colName,
-				Comment:      colComment,
-				DataType:     dataType,
-				Constraint:   constraint,
-				DefaultValue: defaultVal,
-			})
-		}
-		if err := rows.Err(); err != nil {
-			rows.Close()
-			return err
-		}
-		rows.Close()
-
-		defs = append(defs, TableDef{
-			Name:    tn,
-			Comment: tableComment,
-			Columns: cols,
-		})
-	}
-
-	if len(defs) == 0 {
-		return errors.New("table not found")
-	}
-
-	f := excelize.NewFile()
-	var (
-		rowHeaderStyle, _          = f.NewStyle(&excelize.Style{Border: []excelize.Border{{Type: "top", Style: 1, Color: "000000"}, {Type: "left", Style: 1, Color: "000000"}, {Type: "right", Style: 1, Color: "000000"}, {Type: "bottom", Style: 1, Color: "000000"}}, Fill: excelize.Fill{Type: "pattern", Color: []string{"#D9D9D9"}, Pattern: 1}})
-		columnHeaderStyle, _       = f.NewStyle(&excelize.Style{Border: []excelize.Border{{Type: "top", Style: 1, Color: "#000000"}, {Type: "left", Style: 1, Color: "#000000"}, {Type: "right", Style: 1, Color: "#000000"}, {Type: "bottom", Style: 1, Color: "#000000"}}, Fill: excelize.Fill{Type: "pattern", Color: []string{"#FCD5B4"}, Pattern: 1}})
-		columnHeaderSystemStyle, _ = f.NewStyle(&excelize.Style{Border: []excelize.Border{{Type: "top", Style: 1, Color: "000000"}, {Type: "left", Style: 1, Color: "000000"}, {Type: "right", Style: 1, Color: "000000"}, {Type: "bottom", Style: 1, Color: "000000"}}, Fill: excelize.Fill{Type: "pattern", Color: []string{"#BFBFBF"}, Pattern: 1}})
-		rowStyle, _                = f.NewStyle(&excelize.Style{Border: []excelize.Border{{Type: "top", Style: 1, Color: "000000"}, {Type: "left", Style: 1, Color: "000000"}, {Type: "right", Style: 1, Color: "000000"}, {Type: "bottom", Style: 1, Color: "000000"}}})
-		valueTextStyle, _          = f.NewStyle(&excelize.Style{NumFmt: 49, Border: []excelize.Border{{Type: "top", Style: 1, Color: "000000"}, {Type: "left", Style: 1, Color: "000000"}, {Type: "right", Style: 1, Color: "000000"}, {Type: "bottom", Style: 1, Color: "000000"}}})
-	)
-
-	for i, tableDef := range defs {
-		sheetName := tableDef.Comment
-		if len(sheetName) == 0 {
-			sheetName = tableDef.Name
-		}
-		index := f.NewSheet(sheetName)
-		if i == 0 {
-			f.SetActiveSheet(index)
-		}
-		_ = f.SetCellValue(sheetName, "A1", tableDef.Comment)
-		_ = f.SetCellValue(sheetName, "A2", tableDef.Name)
-		_ = f.SetCellValue(sheetName, "A3", "version")
-		_ = f.SetCellValue(sheetName, "B3", "2.0")
-		_ = f.SetCellValue(sheetName, "A5", "項目名")
-		_ = f.SetCellValue(sheetName, "A6", "項目物理名")
-		_ = f.SetColWidth(sheetName, "A", "A", 12.86)
-		_ = f.SetCellStyle(sheetName, "A5", "A6", rowHeaderStyle)
-
-		for i, columnDef := range tableDef.Columns {
-			axisComment, _ := excelize.CoordinatesToCellName(2+i, 5)
-			axisName, _ := excelize.CoordinatesToCellName(2+i, 6)
-			_ = f.SetCellValue(sheetName, axisComment, columnDef.Comment)
-			_ = f.SetCellValue(sheetName, axisName, columnDef.Name)
-			currentCol, _ := excelize.ColumnNumberToName(2 + i)
-			width := utf8.RuneCountInString(columnDef.Comment) * 2
-			if width < utf8.RuneCountInString(columnDef.Name) {
-				width = utf8.RuneCountInString(columnDef.Name)
-			}
-			width += 2
-			_ = f.SetColWidth(sheetName, currentCol, currentCol, float64(width))
-			style := columnHeaderStyle
-			if slices.Contains(systemColumn, columnDef.Name) {
-				style = columnHeaderSystemStyle
-			}
-			_ = f.SetCellStyle(sheetName, axisComment, axisName, style)
-		}
-
-		// データ取得
-		rows, err := db.QueryContext(ctx, fmt.Sprintf("SELECT * FROM %s LIMIT %d", tableDef.Name, maxDumpSize))
-		if err != nil {
-			return fmt.Errorf("select exists records: %w", err)
-		}
-		defer rows.Close()
-
-		cols, err := rows.Columns()
-		if err != nil {
-			return err
-		}
-		// スキーマと一致しない場合は列数に合わせて調整
-		colCount := len(cols)
-		if colCount > len(tableDef.Columns) {
-			colCount = len(tableDef.Columns)
-		}
-
-		var records [][]any
-		for rows.Next() {
-			g := make([]any, colCount)
-			for i := range g {
-				g[i] = &g[i]
-			}
-			if err := rows.Scan(g...); err != nil {
-				return err
-			}
-			records = append(records, g)
-		}
-		if err := rows.Err(); err != nil {
-			return err
-		}
-
-		vCell, _ := excelize.CoordinatesToCellName(1+len(tableDef.Columns), 12)
-		_ = f.SetCellStyle(sheetName, "A7", vCell, rowStyle)
-
-		if len(records) > 0 {
-			vCell, _ := excelize.CoordinatesToCellName(len(records[0])+1, len(records)+9)
-			_ = f.SetCellStyle(sheetName, "A10", vCell, rowStyle)
-			for i, record := range records {
-				rowNum := 7 + i
-				vCell, _ := excelize.CoordinatesToCellName(1, rowNum)
-				_ = f.SetCellValue(sheetName, vCell, fmt.Sprint(i+1))
-				for j, cell := range record {
-					colNum := j + 2
-					vCell, _ := excelize.CoordinatesToCellName(colNum, rowNum)
-					_ = f.SetCellValue(sheetName, vCell, fmtCell(cell))
-					_ = f.SetCellStyle(sheetName, vCell, vCell, valueTextStyle)
-				}
-			}
-		}
-	}
-
-	f.DeleteSheet("Sheet1")
-	if err := f.SaveAs(targetFile); err != nil {
-		return fmt.Errorf("dump result save: %w", err)
-	}
-	return nil
+	return spec, nil
 }
 
-func selectTabColumnDef(ctx context.Context, conn *pgxpool.Pool, tableNames []string) ([]TableDef, error) {
-	sql := query
-	if len(tableNames) == 0 {
-		sql = queryAll
+// mergeDumpSpec はYAMLのダンプ設定 spec を opt にマージします
+func mergeDumpSpec(spec dumpSpec, opt exceltesting.DumpOptions) exceltesting.DumpOptions {
+	if opt.Where == nil {
+		opt.Where = make(map[string]string)
 	}
-
-	rows, err := conn.Query(ctx, sql)
-	if err != nil {
-		return nil, fmt.Errorf("db access: %w", err)
+	if opt.OrderBy == nil {
+		opt.OrderBy = make(map[string][]string)
 	}
-	defer rows.Close()
-
-	var (
-		tableDefs    = make([]TableDef, 0, len(tableNames))
-		columnDefs   []ColumnDef
-		currentTable string
-	)
-
-	for rows.Next() {
-		g := make([]any, 7) // Name, Comment, ColumnName, ColumnComment, DataType, ColumnConstraint, DefaultValue
-		for i := range g {
-			g[i] = &g[i]
+	for _, t := range spec.Tables {
+		if !slices.Contains(opt.Tables, t.Name) {
+			opt.Tables = append(opt.Tables, t.Name)
 		}
-		if err := rows.Scan(g...); err != nil {
-			return nil, err
+		if _, ok := opt.Where[t.Name]; !ok && t.Where != "" {
+			opt.Where[t.Name] = t.Where
 		}
-
-		if currentTable != Str(g[0]) {
-			columnDefs = make([]ColumnDef, 0, DefaultColumnCnt)
-
-			tableDefs = append(tableDefs, TableDef{
-				Name:    Str(g[0]),
-				Comment: Str(g[1]),
-			})
-			currentTable = Str(g[0])
+		if _, ok := opt.OrderBy[t.Name]; !ok && len(t.OrderBy) > 0 {
+			opt.OrderBy[t.Name] = t.OrderBy
 		}
-
-		columnDefs = append(columnDefs, ColumnDef{
-			Name:         Str(g[2]),
-			Comment:      Str(g[3]),
-			DataType:     Str(g[4]),
-			Constraint:   Str(g[5]),
-			DefaultValue: Str(g[6]),
-		})
-		tableDefs[len(tableDefs)-1].Columns = columnDefs
-
 	}
-	if err := rows.Err(); err != nil {
-		return nil, err
-	}
-
-	return tableDefs, nil
-}
 
-func Str(a any) string {
-	if a == nil {
-		return ""
+	queries := make([]exceltesting.DumpQuery, 0, len(spec.Queries)+len(opt.Queries))
+	for _, q := range spec.Queries {
+		queries = append(queries, exceltesting.DumpQuery{Name: q.Name, Query: q.Query})
 	}
-	return a.(string)
+	opt.Queries = append(queries, opt.Queries...)
+	return opt
 }
 
-func selectExistsRecords(ctx context.Context, conn *pgxpool.Pool, tableDef TableDef, maxDumpSize int) ([][]any, error) {
-	rows, err := conn.Query(ctx, fmt.Sprintf(`select * from %s limit %d`, tableDef.Name, maxDumpSize))
-	if err != nil {
-		return nil, fmt.Errorf("db access: %w", err)
-	}
-	defer rows.Close()
-
-	dataRecords := make([][]any, 0, maxDumpSize)
-
-	for rows.Next() {
-		g := make([]any, len(tableDef.Columns))
-		for i := range g {
-			g[i] = &g[i]
-		}
-		if err := rows.Scan(g...); err != nil {
-			return nil, err
+// parseDumpQueries は name=SELECT ... 形式の値をシート名とSELECT文に分割します
+func parseDumpQueries(vs []string) ([]exceltesting.DumpQuery, error) {
+	queries := make([]exceltesting.DumpQuery, 0, len(vs))
+	for _, v := range vs {
+		name, query, ok := strings.Cut(v, "=")
+		if !ok || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("invalid query %q, must be name=SELECT ...", v)
 		}
-
-		dataRecords = append(dataRecords, g)
-	}
-	if err := rows.Err(); err != nil {
-		return nil, err
+		queries = append(queries, exceltesting.DumpQuery{Name: strings.TrimSpace(name), Query: query})
 	}
-
-	return dataRecords, nil
+	return queries, nil
 }
 
-func fmtCell(a any) string {
-	switch v := a.(type) {
-	case time.Time:
-		return v.Format("2006-01-02 15:04:05")
-	case pgtype.Numeric:
-		var s string
-		if err := v.AssignTo(&s); err != nil {
-			return fmt.Sprintf("%v (fmtCell): %v", v, err)
+// parseDumpRoots は table:column=value[,column=value] 形式の値を起点のテーブルとWHERE句の条件に変換します
+func parseDumpRoots(vs []string) ([]exceltesting.DumpRoot, error) {
+	roots := make([]exceltesting.DumpRoot, 0, len(vs))
+	for _, v := range vs {
+		table, conds, ok := strings.Cut(v, ":")
+		if !ok || strings.TrimSpace(table) == "" {
+			return nil, fmt.Errorf("invalid root %q, must be table:column=value", v)
+		}
+		var where []string
+		for _, cond := range strings.Split(conds, ",") {
+			column, value, ok := strings.Cut(cond, "=")
+			if !ok || strings.TrimSpace(column) == "" {
+				return nil, fmt.Errorf("invalid root %q, must be table:column=value", v)
+			}
+			where = append(where, fmt.Sprintf("%s = '%s'", strings.TrimSpace(column), strings.ReplaceAll(value, "'", "''")))
 		}
-		return s
-	case []byte:
-		return string(v)
-	default:
-		return fmt.Sprint(v)
+		roots = append(roots, exceltesting.DumpRoot{Table: strings.TrimSpace(table), Where: strings.Join(where, " AND ")})
 	}
+	return roots, nil
 }
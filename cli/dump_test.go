@@ -1,9 +1,12 @@
 package cli
 
 import (
+    "github.com/fc-shota-miyazaki/go-exceltesting"
     "github.com/fc-shota-miyazaki/go-exceltesting/testonly"
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/xuri/excelize/v2"
+	"os"
 	"path/filepath"
 	"testing"
 )
@@ -42,7 +45,13 @@ func Test_dump(t *testing.T) {
 	for _, tt := range tests {
 
 		t.Run(tt.name, func(t *testing.T) {
-			if err := Dump(tt.args.dbSource, tt.args.targetFile, tt.args.tableNameArg, tt.args.systemColumnArg, 10); (err != nil) != tt.wantErr {
+			opt := exceltesting.DumpOptions{
+				TargetBookPath: tt.args.targetFile,
+				Tables:         splitList(tt.args.tableNameArg),
+				SystemColumns:  splitList(tt.args.systemColumnArg),
+				Limit:          10,
+			}
+			if err := Dump(tt.args.dbSource, "", opt); (err != nil) != tt.wantErr {
 				t.Errorf("dump() error = %v, wantErr %v", err, tt.wantErr)
 			}
 		})
@@ -58,3 +67,117 @@ func Test_dump(t *testing.T) {
 
 	}
 }
+
+func Test_mergeDumpSpec(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dump.yaml")
+	spec := `tables:
+  - name: orders
+    where: created_at > now() - interval '1 day'
+    orderBy: [created_at, id]
+  - name: company
+    where: company_cd = '00001'
+queries:
+  - name: 会社ごとの注文数
+    query: SELECT company_cd, count(*) FROM orders GROUP BY company_cd
+`
+	if err := os.WriteFile(path, []byte(spec), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s, err := readDumpSpec(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := mergeDumpSpec(s, exceltesting.DumpOptions{
+		Tables:  []string{"company"},
+		Where:   map[string]string{"company": "company_cd = '00002'"},
+		Queries: []exceltesting.DumpQuery{{Name: "flag", Query: "SELECT 1"}},
+	})
+	want := exceltesting.DumpOptions{
+		Tables: []string{"company", "orders"},
+		Where: map[string]string{
+			"orders":  "created_at > now() - interval '1 day'",
+			"company": "company_cd = '00002'",
+		},
+		OrderBy: map[string][]string{"orders": {"created_at", "id"}},
+		Queries: []exceltesting.DumpQuery{
+			{Name: "会社ごとの注文数", Query: "SELECT company_cd, count(*) FROM orders GROUP BY company_cd"},
+			{Name: "flag", Query: "SELECT 1"},
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mergeDumpSpec() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func Test_parseDumpQueries(t *testing.T) {
+	tests := []struct {
+		name    string
+		vs      []string
+		want    []exceltesting.DumpQuery
+		wantErr bool
+	}{
+		{
+			name: "query contains equal sign",
+			vs:   []string{"recent=SELECT * FROM orders WHERE status = 'open'"},
+			want: []exceltesting.DumpQuery{{Name: "recent", Query: "SELECT * FROM orders WHERE status = 'open'"}},
+		},
+		{
+			name:    "no name",
+			vs:      []string{"SELECT 1"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseDumpQueries(tt.vs)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseDumpQueries() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if diff := cmp.Diff(tt.want, got, cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("parseDumpQueries() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_parseDumpRoots(t *testing.T) {
+	tests := []struct {
+		name    string
+		vs      []string
+		want    []exceltesting.DumpRoot
+		wantErr bool
+	}{
+		{
+			name: "single column",
+			vs:   []string{"customers:id=123"},
+			want: []exceltesting.DumpRoot{{Table: "customers", Where: "id = '123'"}},
+		},
+		{
+			name: "multiple columns with quote",
+			vs:   []string{"order_line:order_id=1,note=it's"},
+			want: []exceltesting.DumpRoot{{Table: "order_line", Where: "order_id = '1' AND note = 'it''s'"}},
+		},
+		{
+			name:    "no table",
+			vs:      []string{"id=123"},
+			wantErr: true,
+		},
+		{
+			name:    "no value",
+			vs:      []string{"customers:id"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseDumpRoots(tt.vs)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseDumpRoots() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if diff := cmp.Diff(tt.want, got, cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("parseDumpRoots() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
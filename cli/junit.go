@@ -0,0 +1,62 @@
+package cli
+
+import (
+	"encoding/xml"
+	"io"
+
+	"github.com/fc-shota-miyazaki/go-exceltesting"
+)
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Errors   int             `xml:"errors,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// writeJUnit は比較結果をJUnit XML形式で出力します。シートを1つのテストケースとして扱います
+func writeJUnit(w io.Writer, res *exceltesting.CompareResult) error {
+	suite := junitTestSuite{Name: "exceltesting compare"}
+	for _, d := range res.Tables {
+		c := junitTestCase{Name: d.Sheet, ClassName: d.Table}
+		switch {
+		case d.Err != nil:
+			c.Error = &junitMessage{Message: "error", Text: d.Err.Error()}
+			suite.Errors++
+		case !d.Equal():
+			c.Failure = &junitMessage{Message: "mismatch", Text: d.String()}
+			suite.Failures++
+		}
+		suite.Cases = append(suite.Cases, c)
+		suite.Tests++
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	e := xml.NewEncoder(w)
+	e.Indent("", "  ")
+	if err := e.Encode(junitTestSuites{Suites: []junitTestSuite{suite}}); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,53 @@
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/fc-shota-miyazaki/go-exceltesting"
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_writeJUnit(t *testing.T) {
+	res := &exceltesting.CompareResult{
+		Tables: []exceltesting.TableDiff{
+			{
+				Table: "company",
+				Sheet: "会社",
+				ChangedCells: []exceltesting.CellDiff{
+					{Row: 1, Column: "founded_year", Want: "1989", Got: "9891"},
+				},
+			},
+			{
+				Table: "temperature",
+				Sheet: "気温",
+			},
+			{
+				Sheet: "不正",
+				Err:   errors.New("failed to load excel sheet"),
+			},
+		},
+	}
+
+	want := `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites>
+  <testsuite name="exceltesting compare" tests="3" failures="1" errors="1">
+    <testcase name="会社" classname="company">
+      <failure message="mismatch">table(company) mismatch, sheet = 会社:&#xA;  row 1: founded_year: want 1989, got 9891</failure>
+    </testcase>
+    <testcase name="気温" classname="temperature"></testcase>
+    <testcase name="不正" classname="">
+      <error message="error">failed to load excel sheet</error>
+    </testcase>
+  </testsuite>
+</testsuites>
+`
+	var b bytes.Buffer
+	if err := writeJUnit(&b, res); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(want, b.String()); diff != "" {
+		t.Errorf("writeJUnit() mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,15 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/fc-shota-miyazaki/go-exceltesting"
+)
+
+// SQL はデータベースに接続せずに、loadコマンドで実行されるSQLスクリプトを出力します
+func SQL(r exceltesting.LoadRequest) error {
+	if err := exceltesting.DryRun(r); err != nil {
+		return fmt.Errorf("dry run: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,235 @@
+package exceltesting
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/exp/slices"
+)
+
+// rowNumColumn は一時テーブルに期待値のA列の番号を保持するカラム名です
+const rowNumColumn = "exceltesting_row"
+
+// matching は期待値の行とDBの行を突き合わせる方法です
+type matching struct {
+	// keys は行を特定するキーとなるカラムです
+	keys []string
+	// orderBy は CompareRequest.OrderBy で指定された並び順です。keys がない場合に並び順で突き合わせます
+	orderBy []string
+}
+
+// orderClause は比較するクエリのORDER BY句のカラムを返します。並び順がない場合は空文字を返します
+func (m matching) orderClause() string {
+	if len(m.keys) > 0 {
+		return strings.Join(m.keys, ",")
+	}
+	return strings.Join(m.orderBy, ",")
+}
+
+// diff はキー、並び順、またはすべてのカラムの値で行を突き合わせ、差分を返します
+func (m matching) diff(want, got []compareRow) (*TableDiff, error) {
+	switch {
+	case len(m.keys) > 0:
+		return diffRows(want, got, m.keys)
+	case len(m.orderBy) > 0:
+		return diffRowsInOrder(want, got), nil
+	default:
+		return diffRowsAsMultiset(want, got), nil
+	}
+}
+
+// compareRow は比較対象の1行です
+type compareRow struct {
+	// num は期待値のA列の番号です。DBから取得した行の場合は0です
+	num    int
+	values []x
+	// matchers は values に対応するマッチャーです。マッチャーでない場合は nil です
+	matchers []*cellMatcher
+}
+
+// wantRows は期待値の値 vs に、Excelの行の位置 idxs に対応するA列の番号とマッチャーを付与します
+func wantRows(t *table, vs [][]x, idxs []int, matchers [][]*cellMatcher) []compareRow {
+	rows := make([]compareRow, 0, len(vs))
+	for i, v := range vs {
+		idx := idxs[i]
+		r := compareRow{num: t.rowNum(idx), values: v, matchers: make([]*cellMatcher, len(v))}
+		for j := range v {
+			k := slices.Index(t.columns, v[j].column)
+			if k < 0 || matchers[idx][k] == nil {
+				continue
+			}
+			r.matchers[j] = matchers[idx][k]
+			// 差分の出力ではマッチャーをそのまま表示する
+			r.values[j].value = matchers[idx][k].src
+		}
+		rows = append(rows, r)
+	}
+	return rows
+}
+
+// diffRows は期待値の行とDBの行をキーとなるカラムの値で突き合わせ、差分を返します。
+// 期待値にのみ存在する行、DBにのみ存在する行、値が異なるセルを報告します。
+func diffRows(want, got []compareRow, keys []string) (*TableDiff, error) {
+	idx, err := keyIndexes(want, got, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	gotByKey := make(map[string][]int, len(got))
+	for i, r := range got {
+		k := rowKey(r, idx)
+		gotByKey[k] = append(gotByKey[k], i)
+	}
+
+	for _, w := range want {
+		for _, i := range idx {
+			if i < len(w.matchers) && w.matchers[i] != nil {
+				return nil, fmt.Errorf("matcher %s cannot be used in key column %s, row = %d", w.matchers[i].src, w.values[i].column, w.num)
+			}
+		}
+	}
+
+	d := &TableDiff{}
+	matched := make([]bool, len(got))
+	for _, w := range want {
+		k := rowKey(w, idx)
+		is := gotByKey[k]
+		if len(is) == 0 {
+			d.MissingRows = append(d.MissingRows, w.toRow())
+			continue
+		}
+		gotByKey[k] = is[1:]
+		matched[is[0]] = true
+
+		d.ChangedCells = append(d.ChangedCells, diffCells(w, got[is[0]])...)
+	}
+	for i, r := range got {
+		if !matched[i] {
+			d.ExtraRows = append(d.ExtraRows, r.toRow())
+		}
+	}
+	return d, nil
+}
+
+// diffRowsInOrder は期待値の行とDBの行を並び順で突き合わせ、差分を返します
+func diffRowsInOrder(want, got []compareRow) *TableDiff {
+	d := &TableDiff{}
+	for i, w := range want {
+		if i >= len(got) {
+			d.MissingRows = append(d.MissingRows, w.toRow())
+			continue
+		}
+		d.ChangedCells = append(d.ChangedCells, diffCells(w, got[i])...)
+	}
+	for i := len(want); i < len(got); i++ {
+		d.ExtraRows = append(d.ExtraRows, got[i].toRow())
+	}
+	return d
+}
+
+// diffRowsAsMultiset は行の順序を無視し、すべてのカラムの値が一致する行を突き合わせます。
+// 同じ値の行が複数ある場合は、その件数の差を期待値にのみ存在する行、DBにのみ存在する行として報告します。
+func diffRowsAsMultiset(want, got []compareRow) *TableDiff {
+	// マッチャーを含む行は任意の行に一致しうるため、値が確定している行から突き合わせる
+	ordered := make([]compareRow, 0, len(want))
+	for _, w := range want {
+		if !w.hasMatcher() {
+			ordered = append(ordered, w)
+		}
+	}
+	for _, w := range want {
+		if w.hasMatcher() {
+			ordered = append(ordered, w)
+		}
+	}
+
+	d := &TableDiff{}
+	matched := make([]bool, len(got))
+	for _, w := range ordered {
+		found := false
+		for i, g := range got {
+			if !matched[i] && len(diffCells(w, g)) == 0 {
+				matched[i] = true
+				found = true
+				break
+			}
+		}
+		if !found {
+			d.MissingRows = append(d.MissingRows, w.toRow())
+		}
+	}
+	slices.SortStableFunc(d.MissingRows, func(a, b Row) bool { return a.Row < b.Row })
+	for i, r := range got {
+		if !matched[i] {
+			d.ExtraRows = append(d.ExtraRows, r.toRow())
+		}
+	}
+	return d
+}
+
+// diffCells は突き合わせた期待値の行 w とDBの行 g で、値が異なるセルを返します
+func diffCells(w, g compareRow) []CellDiff {
+	var ds []CellDiff
+	for j, c := range w.values {
+		if j < len(w.matchers) && w.matchers[j] != nil {
+			if !w.matchers[j].match(g.values[j]) {
+				ds = append(ds, CellDiff{Row: w.num, Column: c.column, Want: c.value, Got: g.values[j].value})
+			}
+			continue
+		}
+		if c.value != g.values[j].value {
+			ds = append(ds, CellDiff{Row: w.num, Column: c.column, Want: c.value, Got: g.values[j].value})
+		}
+	}
+	return ds
+}
+
+// keyIndexes はキーとなるカラムの位置を返します
+func keyIndexes(want, got []compareRow, keys []string) ([]int, error) {
+	var rows []compareRow
+	rows = append(rows, want...)
+	rows = append(rows, got...)
+	if len(rows) == 0 {
+		return nil, nil
+	}
+
+	columns := make([]string, 0, len(rows[0].values))
+	for _, v := range rows[0].values {
+		columns = append(columns, v.column)
+	}
+
+	idx := make([]int, 0, len(keys))
+	for _, k := range keys {
+		i := slices.Index(columns, strings.TrimSpace(k))
+		if i < 0 {
+			return nil, fmt.Errorf("key column %s is not compared. add it to the sheet or remove it from IgnoreColumns", strings.TrimSpace(k))
+		}
+		idx = append(idx, i)
+	}
+	return idx, nil
+}
+
+func rowKey(r compareRow, idx []int) string {
+	vs := make([]string, 0, len(idx))
+	for _, i := range idx {
+		vs = append(vs, r.values[i].value)
+	}
+	return strings.Join(vs, "\x00")
+}
+
+func (r compareRow) hasMatcher() bool {
+	for _, m := range r.matchers {
+		if m != nil {
+			return true
+		}
+	}
+	return false
+}
+
+func (r compareRow) toRow() Row {
+	cs := make([]Cell, 0, len(r.values))
+	for _, v := range r.values {
+		cs = append(cs, Cell{Column: v.column, Value: v.value})
+	}
+	return Row{Row: r.num, Cells: cs}
+}
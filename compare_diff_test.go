@@ -0,0 +1,169 @@
+package exceltesting
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_diffRows(t *testing.T) {
+	row := func(num int, cd, name, year string) compareRow {
+		return compareRow{num: num, values: []x{
+			{column: "company_cd", value: cd},
+			{column: "company_name", value: name},
+			{column: "founded_year", value: year},
+		}}
+	}
+
+	tests := []struct {
+		name    string
+		want    []compareRow
+		got     []compareRow
+		keys    []string
+		wantErr bool
+		wantOut string
+	}{
+		{
+			name:    "equal",
+			want:    []compareRow{row(1, "00001", "Future", "1989"), row(2, "00002", "YDC", "1972")},
+			got:     []compareRow{row(0, "00001", "Future", "1989"), row(0, "00002", "YDC", "1972")},
+			keys:    []string{"company_cd"},
+			wantOut: "table(company) mismatch, sheet = 会社:",
+		},
+		{
+			name: "missing row does not shift following rows",
+			want: []compareRow{row(1, "00001", "Future", "1989"), row(2, "00002", "YDC", "1972"), row(3, "00003", "FutureOne", "2002")},
+			got:  []compareRow{row(0, "00001", "Future", "1989"), row(0, "00003", "FutureOne", "2002")},
+			keys: []string{"company_cd"},
+			wantOut: `table(company) mismatch, sheet = 会社:
+  missing row 2: company_cd=00002, company_name=YDC, founded_year=1972`,
+		},
+		{
+			name: "unexpected row and changed cells",
+			want: []compareRow{row(1, "00001", "Future", "1989")},
+			got:  []compareRow{row(0, "00001", "", "9891"), row(0, "00002", "YDC", "1972")},
+			keys: []string{"company_cd"},
+			wantOut: `table(company) mismatch, sheet = 会社:
+  unexpected row: company_cd=00002, company_name=YDC, founded_year=1972
+  row 1: company_name: want Future, got ""
+  row 1: founded_year: want 1989, got 9891`,
+		},
+		{
+			name: "matchers",
+			want: func() []compareRow {
+				r := row(1, "00001", "<notnull>", ">=2000")
+				name, _ := parseMatcher("<notnull>")
+				year, _ := parseMatcher(">=2000")
+				r.matchers = []*cellMatcher{nil, name, year}
+				return []compareRow{r}
+			}(),
+			got:  []compareRow{row(0, "00001", "Future", "1989")},
+			keys: []string{"company_cd"},
+			wantOut: `table(company) mismatch, sheet = 会社:
+  row 1: founded_year: want >=2000, got 1989`,
+		},
+		{
+			name: "matcher in key column",
+			want: func() []compareRow {
+				r := row(1, "<any>", "Future", "1989")
+				cd, _ := parseMatcher("<any>")
+				r.matchers = []*cellMatcher{cd, nil, nil}
+				return []compareRow{r}
+			}(),
+			got:     []compareRow{row(0, "00001", "Future", "1989")},
+			keys:    []string{"company_cd"},
+			wantErr: true,
+		},
+		{
+			name:    "key column is not compared",
+			want:    []compareRow{row(1, "00001", "Future", "1989")},
+			got:     []compareRow{row(0, "00001", "Future", "1989")},
+			keys:    []string{"id"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := diffRows(tt.want, tt.got, tt.keys)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("diffRows() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			d.Table = "company"
+			d.Sheet = "会社"
+			if diff := cmp.Diff(tt.wantOut, d.String()); diff != "" {
+				t.Errorf("diffRows() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_diffRowsAsMultiset(t *testing.T) {
+	row := func(num int, event string) compareRow {
+		return compareRow{num: num, values: []x{{column: "event", value: event}}}
+	}
+
+	tests := []struct {
+		name    string
+		want    []compareRow
+		got     []compareRow
+		wantOut string
+	}{
+		{
+			name:    "different order",
+			want:    []compareRow{row(1, "login"), row(2, "login"), row(3, "logout")},
+			got:     []compareRow{row(0, "logout"), row(0, "login"), row(0, "login")},
+			wantOut: "table(audit_log) mismatch, sheet = 監査ログ:",
+		},
+		{
+			name: "duplicates",
+			want: []compareRow{row(1, "login"), row(2, "login"), row(3, "logout"), row(4, "logout")},
+			got:  []compareRow{row(0, "login"), row(0, "login"), row(0, "login"), row(0, "logout")},
+			wantOut: `table(audit_log) mismatch, sheet = 監査ログ:
+  missing row 4: event=logout
+  unexpected row: event=login`,
+		},
+		{
+			name: "matcher is matched after exact rows",
+			want: func() []compareRow {
+				r := row(1, "<notnull>")
+				m, _ := parseMatcher("<notnull>")
+				r.matchers = []*cellMatcher{m}
+				return []compareRow{r, row(2, "login")}
+			}(),
+			got:     []compareRow{row(0, "login"), row(0, "logout")},
+			wantOut: "table(audit_log) mismatch, sheet = 監査ログ:",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := diffRowsAsMultiset(tt.want, tt.got)
+			d.Table = "audit_log"
+			d.Sheet = "監査ログ"
+			if diff := cmp.Diff(tt.wantOut, d.String()); diff != "" {
+				t.Errorf("diffRowsAsMultiset() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_diffRowsInOrder(t *testing.T) {
+	row := func(num int, event string) compareRow {
+		return compareRow{num: num, values: []x{{column: "event", value: event}}}
+	}
+
+	d := diffRowsInOrder(
+		[]compareRow{row(1, "login"), row(2, "logout")},
+		[]compareRow{row(0, "logout"), row(0, "logout"), row(0, "login")},
+	)
+	d.Table = "audit_log"
+	d.Sheet = "監査ログ"
+	want := `table(audit_log) mismatch, sheet = 監査ログ:
+  unexpected row: event=login
+  row 1: event: want login, got logout`
+	if diff := cmp.Diff(want, d.String()); diff != "" {
+		t.Errorf("diffRowsInOrder() mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,37 @@
+package exceltesting
+
+import (
+	"context"
+)
+
+// comparativeSourceQuery はクエリシートのSELECT文の結果と、期待値を比較可能な値として取得します。
+// 期待値はクエリの結果のカラムの型に応じて変換します。
+//
+// 行の突き合わせ、比較対象の行の条件は、シート名をキーとして CompareRequest.KeyColumns 、
+// CompareRequest.OrderBy 、CompareRequest.Filters で指定します。
+// いずれも指定しない場合は行の順序を無視して、すべてのカラムの値で突き合わせます。
+func (e *exceltesing) comparativeSourceQuery(ctx context.Context, q queryer, t *table, req *CompareRequest) ([]compareRow, []compareRow, matching, error) {
+	mt := matching{keys: req.KeyColumns[t.optionKey()], orderBy: req.OrderBy[t.optionKey()]}
+
+	query, cs, err := e.buildComparingQuery(t, mt.orderClause(), req)
+	if err != nil {
+		return nil, nil, matching{}, err
+	}
+
+	got, gotTypes, err := e.getComparingData(ctx, q, query, len(cs))
+	if err != nil {
+		return nil, nil, matching{}, err
+	}
+
+	types := make(map[string]string, len(cs))
+	for i, c := range cs {
+		types[c] = gotTypes[i]
+	}
+
+	opt := normalizeOption{timePrecision: req.TimePrecision}
+	want, err := e.castWantRows(ctx, q, t, cs, types, opt)
+	if err != nil {
+		return nil, nil, matching{}, err
+	}
+	return want, toCompareRows(convert(got, cs, gotTypes, opt), nil), mt, nil
+}
@@ -0,0 +1,201 @@
+package exceltesting
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"golang.org/x/exp/slices"
+)
+
+// CompareMode は期待値とDBの値を比較する方式です
+type CompareMode string
+
+const (
+	// CompareModeTempTable は期待値を一時テーブルに投入し、DBの型に変換してから比較します。デフォルトの方式です
+	CompareModeTempTable CompareMode = "temptable"
+	// CompareModeReadOnly は一時テーブルを作成せず、期待値をカラムの型に応じてGoで変換して比較します。
+	// DDLや書き込みを行わないため、読み取り専用のレプリカやTEMP権限のないユーザでも利用できます
+	CompareModeReadOnly CompareMode = "readonly"
+)
+
+// timeLayouts はセルの日時として解釈できる形式です
+var timeLayouts = []string{
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999Z07",
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	"2006/01/02 15:04:05.999999999",
+	"2006-01-02",
+	"2006/01/02",
+}
+
+// comparativeSourceReadOnly は一時テーブルを利用せずに、比較可能な値を取得します。
+// 期待値は information_schema から取得したカラムの型に応じて変換します。
+func (e *exceltesing) comparativeSourceReadOnly(ctx context.Context, q queryer, t *table, req *CompareRequest) ([]compareRow, []compareRow, matching, error) {
+	mt, err := e.rowMatching(ctx, q, t.name, req)
+	if err != nil {
+		return nil, nil, matching{}, err
+	}
+
+	query, cs, err := e.buildComparingQuery(t, mt.orderClause(), req)
+	if err != nil {
+		return nil, nil, matching{}, err
+	}
+
+	got, gotTypes, err := e.getComparingData(ctx, q, query, len(cs))
+	if err != nil {
+		return nil, nil, matching{}, err
+	}
+
+	types, err := e.columnTypes(ctx, q, t.name)
+	if err != nil {
+		return nil, nil, matching{}, fmt.Errorf("get column types: %w", err)
+	}
+
+	opt := normalizeOption{timePrecision: req.TimePrecision}
+	want, err := e.castWantRows(ctx, q, t, cs, types, opt)
+	if err != nil {
+		return nil, nil, matching{}, err
+	}
+	return want, toCompareRows(convert(got, cs, gotTypes, opt), nil), mt, nil
+}
+
+// castWantRows は期待値のセルをカラムの型 types に応じて変換し、マッチャーを付与した行を返します
+func (e *exceltesing) castWantRows(ctx context.Context, q queryer, t *table, cs []string, types map[string]string, opt normalizeOption) ([]compareRow, error) {
+	m, matchers, err := extractMatchers(t)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &cellCaster{q: q, dialect: detectDialect(e.db), opt: opt}
+
+	want := make([][]x, 0, len(m.data))
+	idxs := make([]int, 0, len(m.data))
+	for i, row := range m.data {
+		var w []x
+		for _, column := range cs {
+			j := slices.Index(m.columns, column)
+			v, err := c.cast(ctx, row[j], types[column])
+			if err != nil {
+				return nil, fmt.Errorf("cast %s.%s: %w", t.optionKey(), column, err)
+			}
+			w = append(w, x{column: column, value: v, null: cellSQLExp(row[j]) == "null"})
+		}
+		want = append(want, w)
+		idxs = append(idxs, i)
+	}
+	return wantRows(t, want, idxs, matchers), nil
+}
+
+// columnTypes はテーブルのカラム名と information_schema.columns の data_type の組を返します
+func (e *exceltesing) columnTypes(ctx context.Context, q queryer, tableName string) (map[string]string, error) {
+	query := getTableColumnTypes
+	if detectDialect(e.db) == DialectMySQL {
+		query = getTableColumnTypesMySQL
+	}
+
+	rows, err := q.QueryContext(ctx, query, tableName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	types := make(map[string]string)
+	for rows.Next() {
+		var name, dataType string
+		if err := rows.Scan(&name, &dataType); err != nil {
+			return nil, err
+		}
+		types[name] = dataType
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return types, nil
+}
+
+// cellCaster はExcelのセルの値をカラムの型に応じて比較可能な文字列に変換します
+type cellCaster struct {
+	q       queryer
+	dialect Dialect
+	opt     normalizeOption
+	// loc はタイムゾーンの指定がない timestamp with time zone の値を解釈するセッションのタイムゾーンです
+	loc *time.Location
+}
+
+func (c *cellCaster) cast(ctx context.Context, cell, dataType string) (string, error) {
+	exp := cellSQLExp(cell)
+	kind := kindOfDBType(dataType)
+	if exp == "null" {
+		return normalizeValue(nil, kind, c.opt), nil
+	}
+
+	// current_timestamp などの関数は、書き込みを伴わないSELECTで評価する
+	if slices.Contains(functionNames, exp) {
+		var v any
+		if err := c.q.QueryRowContext(ctx, "SELECT "+exp).Scan(&v); err != nil {
+			return "", err
+		}
+		return normalizeValue(v, kind, c.opt), nil
+	}
+
+	v := strings.Trim(strings.Trim(cell, "　"), " ")
+	switch kind {
+	case kindTime, kindDate:
+		loc := time.UTC
+		if strings.EqualFold(dataType, "timestamp with time zone") || strings.EqualFold(dataType, "timestamptz") {
+			l, err := c.sessionLocation(ctx)
+			if err != nil {
+				return "", err
+			}
+			loc = l
+		}
+		if tm, ok := parseTime(v, loc); ok {
+			return normalizeValue(tm, kind, c.opt), nil
+		}
+		return v, nil
+	case kindBytes:
+		if strings.HasPrefix(v, `\x`) {
+			if b, err := hex.DecodeString(v[2:]); err == nil {
+				return normalizeValue(b, kind, c.opt), nil
+			}
+		}
+		return normalizeValue([]byte(v), kind, c.opt), nil
+	default:
+		return normalizeValue(v, kind, c.opt), nil
+	}
+}
+
+// sessionLocation はセッションのタイムゾーンを返します。MySQLの場合はドライバのデフォルトのUTCを返します
+func (c *cellCaster) sessionLocation(ctx context.Context) (*time.Location, error) {
+	if c.loc != nil {
+		return c.loc, nil
+	}
+	c.loc = time.UTC
+	if c.dialect == DialectMySQL {
+		return c.loc, nil
+	}
+
+	var name string
+	if err := c.q.QueryRowContext(ctx, "SELECT current_setting('TimeZone')").Scan(&name); err != nil {
+		return nil, err
+	}
+	if l, err := time.LoadLocation(name); err == nil {
+		c.loc = l
+	}
+	return c.loc, nil
+}
+
+// parseTime はセルの値を日時として解釈します。タイムゾーンの指定がない場合は loc の時刻とみなします
+func parseTime(v string, loc *time.Location) (time.Time, bool) {
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, v, loc); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,64 @@
+package exceltesting
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_parseTime(t *testing.T) {
+	jst := time.FixedZone("Asia/Tokyo", 9*60*60)
+
+	tests := []struct {
+		name   string
+		v      string
+		loc    *time.Location
+		want   time.Time
+		wantOK bool
+	}{
+		{
+			name:   "with offset",
+			v:      "2022-04-01 09:00:00+09",
+			loc:    time.UTC,
+			want:   time.Date(2022, 4, 1, 0, 0, 0, 0, time.UTC),
+			wantOK: true,
+		},
+		{
+			name:   "RFC3339",
+			v:      "2022-04-01T09:00:00.123+09:00",
+			loc:    time.UTC,
+			want:   time.Date(2022, 4, 1, 0, 0, 0, 123000000, time.UTC),
+			wantOK: true,
+		},
+		{
+			name:   "without offset uses location",
+			v:      "2022/04/01 09:00:00",
+			loc:    jst,
+			want:   time.Date(2022, 4, 1, 0, 0, 0, 0, time.UTC),
+			wantOK: true,
+		},
+		{
+			name:   "date",
+			v:      "2022-04-01",
+			loc:    time.UTC,
+			want:   time.Date(2022, 4, 1, 0, 0, 0, 0, time.UTC),
+			wantOK: true,
+		},
+		{
+			name:   "not a time",
+			v:      "abc",
+			loc:    time.UTC,
+			wantOK: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseTime(tt.v, tt.loc)
+			if ok != tt.wantOK {
+				t.Fatalf("parseTime() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("parseTime() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,215 @@
+package exceltesting
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// CompareResult はExcelのBookとデータベースの値の比較結果です
+type CompareResult struct {
+	// Tables は比較したシートごとの差分です。差分のないシートも含みます
+	Tables []TableDiff `json:"tables"`
+	// Updated は CompareRequest.Update により、差分を期待値のBookに反映した場合に true です
+	Updated bool `json:"updated,omitempty"`
+}
+
+// TableDiff はシート単位の差分です
+type TableDiff struct {
+	// Table はテーブル名です。クエリシートの場合は空文字です
+	Table string `json:"table"`
+	// Sheet はシート名です
+	Sheet string `json:"sheet"`
+	// MissingRows は期待値にのみ存在する行です
+	MissingRows []Row `json:"missingRows,omitempty"`
+	// ExtraRows はデータベースにのみ存在する行です
+	ExtraRows []Row `json:"extraRows,omitempty"`
+	// ChangedCells は値が異なるセルです
+	ChangedCells []CellDiff `json:"changedCells,omitempty"`
+	// NotUpdatedCells は CompareRequest.Update でも期待値のBookに反映しない、一致しないマッチャーのセルです
+	NotUpdatedCells []CellDiff `json:"notUpdatedCells,omitempty"`
+	// Err はシートの読み込みや値の取得に失敗した場合のエラーです
+	Err error `json:"-"`
+}
+
+// Row は比較した行です
+type Row struct {
+	// Row は期待値のA列の番号です。データベースにのみ存在する行の場合は0です
+	Row int `json:"row,omitempty"`
+	// Cells は比較したカラムと値です
+	Cells []Cell `json:"cells"`
+	// Op は CompareDelta で比較した行の操作 (+, -, ~) です。それ以外の比較では空文字です
+	Op string `json:"op,omitempty"`
+}
+
+// Cell はカラムと正規化した値の組です
+type Cell struct {
+	Column string `json:"column"`
+	Value  string `json:"value"`
+}
+
+// CellDiff は値が異なるセルです
+type CellDiff struct {
+	// Row は期待値のA列の番号です
+	Row    int    `json:"row"`
+	Column string `json:"column"`
+	Want   string `json:"want"`
+	Got    string `json:"got"`
+}
+
+// Equal は差分がない場合に true を返します
+func (r *CompareResult) Equal() bool {
+	for _, d := range r.Tables {
+		if !d.Equal() {
+			return false
+		}
+	}
+	return true
+}
+
+// Errors は差分のあるシートごとのエラーを返します
+func (r *CompareResult) Errors() []error {
+	var errs []error
+	for _, d := range r.Tables {
+		switch {
+		case d.Err != nil:
+			errs = append(errs, d.Err)
+		case !d.Equal():
+			errs = append(errs, errors.New(d.String()))
+		}
+	}
+	return errs
+}
+
+// Text は差分をテキスト形式で出力します
+func (r *CompareResult) Text() string {
+	var s []string
+	for _, err := range r.Errors() {
+		s = append(s, err.Error())
+	}
+	return strings.Join(s, "\n")
+}
+
+// JSON は比較結果をJSON形式で出力します
+func (r *CompareResult) JSON() ([]byte, error) {
+	return json.MarshalIndent(r, "", "  ")
+}
+
+// Markdown は差分をMarkdownの表形式で出力します
+func (r *CompareResult) Markdown() string {
+	var b strings.Builder
+	for _, d := range r.Tables {
+		if d.Equal() {
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteString("\n")
+		}
+		if d.Table == "" {
+			fmt.Fprintf(&b, "### %s\n\n", d.Sheet)
+		} else {
+			fmt.Fprintf(&b, "### %s (%s)\n\n", d.Table, d.Sheet)
+		}
+		if d.Err != nil {
+			fmt.Fprintf(&b, "error: %s\n", markdownEscape(d.Err.Error()))
+			continue
+		}
+		b.WriteString("| | row | column | want | got |\n")
+		b.WriteString("| --- | --- | --- | --- | --- |\n")
+		for _, m := range d.MissingRows {
+			fmt.Fprintf(&b, "| missing%s | %d | | %s | |\n", opLabel(m), m.Row, markdownEscape(formatCells(m.Cells)))
+		}
+		for _, m := range d.ExtraRows {
+			fmt.Fprintf(&b, "| unexpected%s | | | | %s |\n", opLabel(m), markdownEscape(formatCells(m.Cells)))
+		}
+		for _, c := range d.ChangedCells {
+			fmt.Fprintf(&b, "| changed | %d | %s | %s | %s |\n", c.Row, c.Column, markdownEscape(displayValue(c.Want)), markdownEscape(displayValue(c.Got)))
+		}
+	}
+	return b.String()
+}
+
+// Equal は差分がない場合に true を返します
+func (d *TableDiff) Equal() bool {
+	return d.Err == nil && len(d.MissingRows) == 0 && len(d.ExtraRows) == 0 && len(d.ChangedCells) == 0
+}
+
+func (d *TableDiff) String() string {
+	if d.Err != nil {
+		return d.Err.Error()
+	}
+
+	var b strings.Builder
+	switch {
+	case d.Table == "":
+		// クエリシートはテーブル名を持たない
+		fmt.Fprintf(&b, "query mismatch, sheet = %s:", d.Sheet)
+	case d.Sheet == "":
+		// CompareDelta でシートに記載していないテーブル
+		fmt.Fprintf(&b, "table(%s) mismatch:", d.Table)
+	default:
+		fmt.Fprintf(&b, "table(%s) mismatch, sheet = %s:", d.Table, d.Sheet)
+	}
+	for _, r := range d.MissingRows {
+		fmt.Fprintf(&b, "\n  missing row %d%s: %s", r.Row, opLabel(r), formatCells(r.Cells))
+	}
+	for _, r := range d.ExtraRows {
+		fmt.Fprintf(&b, "\n  unexpected row%s: %s", opLabel(r), formatCells(r.Cells))
+	}
+	for _, c := range d.ChangedCells {
+		fmt.Fprintf(&b, "\n  row %d: %s: want %s, got %s", c.Row, c.Column, displayValue(c.Want), displayValue(c.Got))
+	}
+	return b.String()
+}
+
+// notUpdatedString は Update で期待値のBookに反映しなかった、一致しないマッチャーのセルを表示します
+func (d *TableDiff) notUpdatedString() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "table(%s) matcher mismatch is not updated, sheet = %s:", d.Table, d.Sheet)
+	for _, c := range d.NotUpdatedCells {
+		fmt.Fprintf(&b, "\n  row %d: %s: want %s, got %s", c.Row, c.Column, displayValue(c.Want), displayValue(c.Got))
+	}
+	return b.String()
+}
+
+// MarshalJSON はエラーを文字列として出力します
+func (d TableDiff) MarshalJSON() ([]byte, error) {
+	type alias TableDiff
+	v := struct {
+		alias
+		Error string `json:"error,omitempty"`
+	}{alias: alias(d)}
+	if d.Err != nil {
+		v.Error = d.Err.Error()
+	}
+	return json.Marshal(v)
+}
+
+func formatCells(cs []Cell) string {
+	s := make([]string, 0, len(cs))
+	for _, c := range cs {
+		s = append(s, c.Column+"="+displayValue(c.Value))
+	}
+	return strings.Join(s, ", ")
+}
+
+// opLabel は CompareDelta で比較した行の操作を表示します
+func opLabel(r Row) string {
+	if r.Op == "" {
+		return ""
+	}
+	return " (" + r.Op + ")"
+}
+
+// displayValue は空文字を区別できるように値を表示します
+func displayValue(v string) string {
+	if v == "" {
+		return `""`
+	}
+	return v
+}
+
+func markdownEscape(s string) string {
+	return strings.NewReplacer("|", `\|`, "\n", "<br>").Replace(s)
+}
@@ -0,0 +1,139 @@
+package exceltesting
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func testCompareResult() *CompareResult {
+	return &CompareResult{
+		Tables: []TableDiff{
+			{
+				Table: "company",
+				Sheet: "会社",
+				MissingRows: []Row{
+					{Row: 2, Cells: []Cell{{Column: "company_cd", Value: "00002"}, {Column: "company_name", Value: "YDC"}}},
+				},
+				ExtraRows: []Row{
+					{Cells: []Cell{{Column: "company_cd", Value: "00003"}, {Column: "company_name", Value: "A|B"}}},
+				},
+				ChangedCells: []CellDiff{
+					{Row: 1, Column: "company_name", Want: "Future", Got: ""},
+				},
+			},
+			{
+				Table: "temperature",
+				Sheet: "気温",
+			},
+			{
+				Sheet: "不正",
+				Err:   errors.New("exceltesting: failed to load excel sheet, sheet = 不正: not found"),
+			},
+		},
+	}
+}
+
+func TestCompareResult_Equal(t *testing.T) {
+	if testCompareResult().Equal() {
+		t.Error("Equal() should return false")
+	}
+	r := &CompareResult{Tables: []TableDiff{{Table: "temperature", Sheet: "気温"}}}
+	if !r.Equal() {
+		t.Error("Equal() should return true")
+	}
+}
+
+func TestCompareResult_Text(t *testing.T) {
+	want := `table(company) mismatch, sheet = 会社:
+  missing row 2: company_cd=00002, company_name=YDC
+  unexpected row: company_cd=00003, company_name=A|B
+  row 1: company_name: want Future, got ""
+exceltesting: failed to load excel sheet, sheet = 不正: not found`
+	if diff := cmp.Diff(want, testCompareResult().Text()); diff != "" {
+		t.Errorf("Text() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestCompareResult_Markdown(t *testing.T) {
+	want := `### company (会社)
+
+| | row | column | want | got |
+| --- | --- | --- | --- | --- |
+| missing | 2 | | company_cd=00002, company_name=YDC | |
+| unexpected | | | | company_cd=00003, company_name=A\|B |
+| changed | 1 | company_name | Future | "" |
+
+### 不正
+
+error: exceltesting: failed to load excel sheet, sheet = 不正: not found
+`
+	if diff := cmp.Diff(want, testCompareResult().Markdown()); diff != "" {
+		t.Errorf("Markdown() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestCompareResult_JSON(t *testing.T) {
+	want := `{
+  "tables": [
+    {
+      "table": "company",
+      "sheet": "会社",
+      "missingRows": [
+        {
+          "row": 2,
+          "cells": [
+            {
+              "column": "company_cd",
+              "value": "00002"
+            },
+            {
+              "column": "company_name",
+              "value": "YDC"
+            }
+          ]
+        }
+      ],
+      "extraRows": [
+        {
+          "cells": [
+            {
+              "column": "company_cd",
+              "value": "00003"
+            },
+            {
+              "column": "company_name",
+              "value": "A|B"
+            }
+          ]
+        }
+      ],
+      "changedCells": [
+        {
+          "row": 1,
+          "column": "company_name",
+          "want": "Future",
+          "got": ""
+        }
+      ]
+    },
+    {
+      "table": "temperature",
+      "sheet": "気温"
+    },
+    {
+      "table": "",
+      "sheet": "不正",
+      "error": "exceltesting: failed to load excel sheet, sheet = 不正: not found"
+    }
+  ]
+}`
+	got, err := testCompareResult().JSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(want, string(got)); diff != "" {
+		t.Errorf("JSON() mismatch (-want +got):\n%s", diff)
+	}
+}
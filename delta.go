@@ -0,0 +1,526 @@
+package exceltesting
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+	"golang.org/x/exp/slices"
+)
+
+// deltaOpColumn は CompareDelta のシートで行の操作を記載するカラム名です
+const deltaOpColumn = "#op"
+
+const (
+	// deltaOpInsert は追加された行です
+	deltaOpInsert = "+"
+	// deltaOpDelete は削除された行です
+	deltaOpDelete = "-"
+	// deltaOpUpdate は更新された行です。キーとなるカラムと更新後の値を記載します
+	deltaOpUpdate = "~"
+)
+
+// savepointDelta は DiffDelta でテーブル単位の失敗を後続のテーブルに影響させないためのセーブポイントです
+const savepointDelta = "exceltesting_delta"
+
+// Snapshot は Snapshot で取得したテーブルの行です。CompareDelta で変更を検証する基準として利用します
+type Snapshot struct {
+	tables []*snapshotTable
+}
+
+// snapshotTable はテーブル単位のスナップショットです
+type snapshotTable struct {
+	name string
+	// keys は行を特定する主キーや一意インデックスのカラムです。ない場合は空です
+	keys    []string
+	columns []string
+	// types は columns に対応する DatabaseTypeName です
+	types []string
+	rows  [][]any
+}
+
+func (s *Snapshot) table(name string) *snapshotTable {
+	for _, t := range s.tables {
+		if t.name == name {
+			return t
+		}
+	}
+	return nil
+}
+
+// DeltaRequest はスナップショットからの変更を比較するための設定です
+type DeltaRequest struct {
+	// 期待する変更を記載したExcelパス
+	TargetBookPath string
+	// 比較対象シートプレフィックス
+	SheetPrefix string
+	// 無視シート
+	IgnoreSheet []string
+	// 無視するカラム名。更新日時などのカラムの変更は報告しません
+	IgnoreColumns []string
+	// SQLSheetPrefix は比較対象から除くSQLシートのシート名のプレフィックスです。
+	// A2セルに "#sql" が記載されたシートは本設定に関わらずSQLシートとして扱います
+	SQLSheetPrefix string
+	// TimePrecision は日時を比較する精度です (e.g. time.Millisecond)。0の場合は切り捨てずに比較します
+	TimePrecision time.Duration
+	// Timeout は比較全体のタイムアウトです。0の場合はタイムアウトしません
+	Timeout time.Duration
+}
+
+// Snapshot はテーブル tables の現在の行を取得します。
+// テスト対象の処理の実行前に取得し、CompareDelta で実行後の変更を検証します。
+func (e *exceltesing) Snapshot(ctx context.Context, tables ...string) (*Snapshot, error) {
+	tx, err := e.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, fmt.Errorf("exceltesting: failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	s := &Snapshot{}
+	for _, name := range tables {
+		st, err := e.snapshotTable(ctx, tx, name)
+		if err != nil {
+			return nil, fmt.Errorf("exceltesting: failed to snapshot table %s: %w", name, err)
+		}
+		s.tables = append(s.tables, st)
+	}
+	return s, nil
+}
+
+func (e *exceltesing) snapshotTable(ctx context.Context, q queryer, name string) (*snapshotTable, error) {
+	mt, err := e.rowMatching(ctx, q, name, &CompareRequest{})
+	if err != nil {
+		return nil, err
+	}
+	columns, err := queryColumns(ctx, q, name)
+	if err != nil {
+		return nil, fmt.Errorf("get columns: %w", err)
+	}
+
+	query, _, err := e.buildComparingQuery(&table{name: name, columns: columns}, mt.orderClause(), &CompareRequest{})
+	if err != nil {
+		return nil, err
+	}
+	rows, types, err := e.getComparingData(ctx, q, query, len(columns))
+	if err != nil {
+		return nil, err
+	}
+	return &snapshotTable{name: name, keys: mt.keys, columns: columns, types: types, rows: rows}, nil
+}
+
+// CompareDelta はスナップショット s からの変更が、Excelに記載した変更と一致するか比較します。
+//
+// シートには比較と同じ形式でテーブルのカラムを記載し、"#op" カラムに行の操作を記載します。
+//
+//   - +: 追加された行
+//   - -: 削除された行
+//   - ~: 更新された行。キーとなるカラムと更新後の値を記載します
+//
+// スナップショットを取得したテーブルで、シートに記載していない変更は想定外の変更として報告します。
+// 更新された行でシートに記載していないカラムの変更も報告します。
+func (e *exceltesing) CompareDelta(t TestingT, s *Snapshot, r DeltaRequest) bool {
+	t.Helper()
+
+	res, err := e.DiffDelta(context.Background(), s, r)
+	if err != nil {
+		t.Errorf("%v", err)
+		return false
+	}
+	return reportCompareResult(t, CompareRequest{TargetBookPath: r.TargetBookPath}, res)
+}
+
+// DiffDelta はスナップショット s からの変更とExcelに記載した変更を比較し、テーブルごとの差分を返します。
+// TableDiff の行の Op は操作を表し、MissingRows は記載したが行われなかった変更、
+// ExtraRows は記載していない変更です。
+func (e *exceltesing) DiffDelta(ctx context.Context, s *Snapshot, r DeltaRequest) (*CompareResult, error) {
+	ctx, cancel := withTimeout(ctx, r.Timeout)
+	defer cancel()
+
+	f, err := excelize.OpenFile(r.TargetBookPath)
+	if err != nil {
+		return nil, fmt.Errorf("exceltesting: failed to open excel file: %w", err)
+	}
+	defer f.Close()
+
+	res := &CompareResult{}
+	sheets := make(map[string]*table)
+	for _, sheet := range f.GetSheetList() {
+		if isSQLSheet(f, sheet, r.SQLSheetPrefix) || isQuerySheet(f, sheet) {
+			continue
+		}
+		if !isTargetSheet(sheet, r.SheetPrefix, r.IgnoreSheet) {
+			continue
+		}
+
+		t, err := e.loadExcelSheet(f, sheet)
+		if err != nil {
+			res.Tables = append(res.Tables, TableDiff{
+				Sheet: sheet,
+				Err:   fmt.Errorf("exceltesting: failed to load excel sheet, sheet = %s: %v", sheet, err),
+			})
+			continue
+		}
+		if s.table(t.name) == nil {
+			res.Tables = append(res.Tables, TableDiff{
+				Table: t.name,
+				Sheet: sheet,
+				Err:   fmt.Errorf("exceltesting: table %s is not in the snapshot, sheet = %s", t.name, sheet),
+			})
+			continue
+		}
+		sheets[t.name] = t
+	}
+
+	tx, err := e.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, fmt.Errorf("exceltesting: failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, st := range s.tables {
+		t := sheets[st.name]
+		var d *TableDiff
+		err := inSavepoint(ctx, tx, savepointDelta, func() error {
+			var err error
+			d, err = e.diffDeltaTable(ctx, tx, st, t, &r)
+			return err
+		})
+		if err != nil {
+			d = &TableDiff{Table: st.name, Err: fmt.Errorf("exceltesting: failed to diff delta of table %s: %w", st.name, err)}
+		}
+		if t != nil {
+			d.Sheet = t.sheet
+		}
+		res.Tables = append(res.Tables, *d)
+	}
+	return res, nil
+}
+
+// diffDeltaTable はスナップショット st からの変更と、シート t に記載した変更の差分を返します。
+// t が nil の場合はすべての変更を想定外の変更として報告します。
+func (e *exceltesing) diffDeltaTable(ctx context.Context, q queryer, st *snapshotTable, t *table, r *DeltaRequest) (*TableDiff, error) {
+	req := &CompareRequest{IgnoreColumns: r.IgnoreColumns}
+	query, cs, err := e.buildComparingQuery(&table{name: st.name, columns: st.columns}, strings.Join(st.keys, ","), req)
+	if err != nil {
+		return nil, err
+	}
+	got, gotTypes, err := e.getComparingData(ctx, q, query, len(cs))
+	if err != nil {
+		return nil, err
+	}
+
+	opt := normalizeOption{timePrecision: r.TimePrecision}
+	beforeRows, beforeTypes := st.project(cs)
+	ch, err := detectChanges(
+		toCompareRows(convert(beforeRows, cs, beforeTypes, opt), nil),
+		toCompareRows(convert(got, cs, gotTypes, opt), nil),
+		st.keys,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	types := make(map[string]string, len(cs))
+	for i, c := range cs {
+		types[c] = gotTypes[i]
+	}
+	want, err := e.deltaWantRows(ctx, q, t, cs, types, r.IgnoreColumns, opt)
+	if err != nil {
+		return nil, err
+	}
+
+	d, err := diffDelta(ch, want, st.keys)
+	if err != nil {
+		return nil, err
+	}
+	d.Table = st.name
+	return d, nil
+}
+
+// project はスナップショットの行と型から、カラム cs の値のみを取り出します
+func (st *snapshotTable) project(cs []string) ([][]any, []string) {
+	idx := make([]int, 0, len(cs))
+	types := make([]string, 0, len(cs))
+	for _, c := range cs {
+		i := slices.Index(st.columns, c)
+		idx = append(idx, i)
+		types = append(types, st.types[i])
+	}
+	rows := make([][]any, 0, len(st.rows))
+	for _, row := range st.rows {
+		vs := make([]any, 0, len(idx))
+		for _, i := range idx {
+			vs = append(vs, row[i])
+		}
+		rows = append(rows, vs)
+	}
+	return rows, types
+}
+
+// deltaWantRows はシート t に記載した行を操作ごとに分け、カラムの型 types に応じて変換した行を返します
+func (e *exceltesing) deltaWantRows(ctx context.Context, q queryer, t *table, cs []string, types map[string]string, ignore []string, opt normalizeOption) (map[string][]compareRow, error) {
+	if t == nil {
+		return nil, nil
+	}
+	opIdx := slices.Index(t.columns, deltaOpColumn)
+	if opIdx < 0 {
+		return nil, fmt.Errorf("column %s is not found in sheet %s", deltaOpColumn, t.sheet)
+	}
+
+	var columns, compared []string
+	for i, c := range t.columns {
+		if i == opIdx {
+			continue
+		}
+		columns = append(columns, c)
+		if slices.Contains(ignore, c) {
+			continue
+		}
+		if !slices.Contains(cs, c) {
+			return nil, fmt.Errorf("column %s is not found in table %s", c, t.name)
+		}
+		compared = append(compared, c)
+	}
+
+	subs := make(map[string]*table)
+	for i, row := range t.data {
+		op := strings.TrimSpace(row[opIdx])
+		switch op {
+		case deltaOpInsert, deltaOpDelete, deltaOpUpdate:
+		default:
+			return nil, fmt.Errorf("row = %d: invalid operation %q, must be one of %s, %s, %s", t.rowNum(i), op, deltaOpInsert, deltaOpDelete, deltaOpUpdate)
+		}
+		sub, ok := subs[op]
+		if !ok {
+			sub = &table{sheet: t.sheet, name: t.name, columns: columns}
+			subs[op] = sub
+		}
+		data := make([]string, 0, len(columns))
+		data = append(data, row[:opIdx]...)
+		data = append(data, row[opIdx+1:]...)
+		sub.data = append(sub.data, data)
+		sub.rowNums = append(sub.rowNums, t.rowNum(i))
+	}
+
+	want := make(map[string][]compareRow, len(subs))
+	for op, sub := range subs {
+		rows, err := e.castWantRows(ctx, q, sub, compared, types, opt)
+		if err != nil {
+			return nil, err
+		}
+		want[op] = rows
+	}
+	return want, nil
+}
+
+// rowChanges はスナップショットを取得してからの実際の変更です
+type rowChanges struct {
+	inserted []compareRow
+	deleted  []compareRow
+	// updated は更新前と更新後の行の組です
+	updated [][2]compareRow
+}
+
+// detectChanges はスナップショットの行 before と現在の行 after から変更を求めます。
+// キー keys がない場合は、すべてのカラムの値が一致しない行を削除と追加とみなします。
+func detectChanges(before, after []compareRow, keys []string) (rowChanges, error) {
+	var ch rowChanges
+
+	idx, err := keyIndexes(before, after, keys)
+	if err != nil {
+		return ch, err
+	}
+	if len(keys) == 0 {
+		// キーがない場合はすべてのカラムの値で突き合わせる
+		for _, rs := range [][]compareRow{before, after} {
+			if len(rs) > 0 {
+				idx = columnIndexes(rs[0], rowColumns(rs[0]))
+				break
+			}
+		}
+	}
+
+	byKey := make(map[string][]int, len(before))
+	for i, r := range before {
+		k := rowKey(r, idx)
+		byKey[k] = append(byKey[k], i)
+	}
+
+	matched := make([]bool, len(before))
+	for _, a := range after {
+		k := rowKey(a, idx)
+		is := byKey[k]
+		if len(is) == 0 {
+			ch.inserted = append(ch.inserted, a)
+			continue
+		}
+		byKey[k] = is[1:]
+		matched[is[0]] = true
+
+		b := before[is[0]]
+		if len(diffCells(b, a)) > 0 {
+			ch.updated = append(ch.updated, [2]compareRow{b, a})
+		}
+	}
+	for i, b := range before {
+		if !matched[i] {
+			ch.deleted = append(ch.deleted, b)
+		}
+	}
+	return ch, nil
+}
+
+// diffDelta は実際の変更 ch と、操作ごとの期待する変更 want の差分を返します
+func diffDelta(ch rowChanges, want map[string][]compareRow, keys []string) (*TableDiff, error) {
+	d := &TableDiff{}
+
+	for _, op := range []string{deltaOpInsert, deltaOpDelete} {
+		ws := want[op]
+		gs := ch.inserted
+		if op == deltaOpDelete {
+			gs = ch.deleted
+		}
+
+		var mt matching
+		if len(ws) > 0 {
+			columns := rowColumns(ws[0])
+			gs = projectRows(gs, columns)
+			if containsAll(columns, keys) {
+				mt.keys = keys
+			}
+		}
+		od, err := mt.diff(ws, gs)
+		if err != nil {
+			return nil, fmt.Errorf("operation %s: %w", op, err)
+		}
+		d.MissingRows = append(d.MissingRows, withOp(od.MissingRows, op)...)
+		d.ExtraRows = append(d.ExtraRows, withOp(od.ExtraRows, op)...)
+		d.ChangedCells = append(d.ChangedCells, od.ChangedCells...)
+	}
+
+	ud, err := diffUpdates(ch.updated, want[deltaOpUpdate], keys)
+	if err != nil {
+		return nil, fmt.Errorf("operation %s: %w", deltaOpUpdate, err)
+	}
+	d.MissingRows = append(d.MissingRows, ud.MissingRows...)
+	d.ExtraRows = append(d.ExtraRows, ud.ExtraRows...)
+	d.ChangedCells = append(d.ChangedCells, ud.ChangedCells...)
+	return d, nil
+}
+
+// diffUpdates は実際に更新された行 updated と、期待する更新 ws をキーで突き合わせます。
+// 期待する更新に記載していないカラムの変更は、更新前の値を期待値とする差分として報告します。
+func diffUpdates(updated [][2]compareRow, ws []compareRow, keys []string) (*TableDiff, error) {
+	d := &TableDiff{}
+	if len(ws) > 0 {
+		if len(keys) == 0 {
+			return nil, fmt.Errorf("table has no primary key, updated rows cannot be matched")
+		}
+		if !containsAll(rowColumns(ws[0]), keys) {
+			return nil, fmt.Errorf("key columns %s are required in the sheet", strings.Join(keys, ", "))
+		}
+	}
+
+	var afterIdx, wantIdx []int
+	if len(updated) > 0 {
+		afterIdx = columnIndexes(updated[0][1], keys)
+	}
+	if len(ws) > 0 {
+		wantIdx = columnIndexes(ws[0], keys)
+	}
+
+	byKey := make(map[string]int, len(updated))
+	for i, u := range updated {
+		byKey[rowKey(u[1], afterIdx)] = i
+	}
+
+	matched := make([]bool, len(updated))
+	for _, w := range ws {
+		for _, i := range wantIdx {
+			if i < len(w.matchers) && w.matchers[i] != nil {
+				return nil, fmt.Errorf("matcher %s cannot be used in key column %s, row = %d", w.matchers[i].src, w.values[i].column, w.num)
+			}
+		}
+		i, ok := byKey[rowKey(w, wantIdx)]
+		if !ok || matched[i] {
+			d.MissingRows = append(d.MissingRows, withOp([]Row{w.toRow()}, deltaOpUpdate)...)
+			continue
+		}
+		matched[i] = true
+
+		before, after := updated[i][0], updated[i][1]
+		columns := rowColumns(w)
+		d.ChangedCells = append(d.ChangedCells, diffCells(w, projectRows([]compareRow{after}, columns)[0])...)
+		// シートに記載していないカラムは更新前の値のままであることを期待する
+		for j, v := range after.values {
+			if !slices.Contains(columns, v.column) && v.value != before.values[j].value {
+				d.ChangedCells = append(d.ChangedCells, CellDiff{Row: w.num, Column: v.column, Want: before.values[j].value, Got: v.value})
+			}
+		}
+	}
+	for i, u := range updated {
+		if !matched[i] {
+			d.ExtraRows = append(d.ExtraRows, withOp([]Row{changedRow(u[0], u[1], keys)}, deltaOpUpdate)...)
+		}
+	}
+	return d, nil
+}
+
+// changedRow はキーとなるカラムと、値が変更されたカラムの更新後の値を持つ行を返します
+func changedRow(before, after compareRow, keys []string) Row {
+	var cs []Cell
+	for j, v := range after.values {
+		if slices.Contains(keys, v.column) || v.value != before.values[j].value {
+			cs = append(cs, Cell{Column: v.column, Value: v.value})
+		}
+	}
+	return Row{Cells: cs}
+}
+
+func withOp(rows []Row, op string) []Row {
+	for i := range rows {
+		rows[i].Op = op
+	}
+	return rows
+}
+
+func rowColumns(r compareRow) []string {
+	columns := make([]string, 0, len(r.values))
+	for _, v := range r.values {
+		columns = append(columns, v.column)
+	}
+	return columns
+}
+
+func columnIndexes(r compareRow, columns []string) []int {
+	idx := make([]int, 0, len(columns))
+	for _, c := range columns {
+		idx = append(idx, slices.IndexFunc(r.values, func(v x) bool { return v.column == c }))
+	}
+	return idx
+}
+
+// projectRows は行 rows からカラム columns の値のみを取り出します
+func projectRows(rows []compareRow, columns []string) []compareRow {
+	ps := make([]compareRow, 0, len(rows))
+	for _, r := range rows {
+		p := compareRow{num: r.num}
+		for _, i := range columnIndexes(r, columns) {
+			p.values = append(p.values, r.values[i])
+		}
+		ps = append(ps, p)
+	}
+	return ps
+}
+
+func containsAll(columns, subset []string) bool {
+	for _, c := range subset {
+		if !slices.Contains(columns, c) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,205 @@
+package exceltesting
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/fc-shota-miyazaki/go-exceltesting/testonly"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func Test_exceltesing_CompareDelta(t *testing.T) {
+	conn := testonly.OpenTestDB(t)
+	defer conn.Close()
+
+	testonly.ExecSQLFile(t, conn, filepath.Join("testdata", "schema", "ddl.sql"))
+
+	seed := `TRUNCATE company;
+INSERT INTO company (company_cd,company_name,founded_year,created_at,updated_at,revision) VALUES ('00001','Future',1989,current_timestamp,current_timestamp,1),('00002','YDC',1972,current_timestamp,current_timestamp,1),('00004','FutureTwo',2010,current_timestamp,current_timestamp,1);`
+	change := `INSERT INTO company (company_cd,company_name,founded_year,created_at,updated_at,revision) VALUES ('00003','FutureOne',2002,current_timestamp,current_timestamp,1);
+UPDATE company SET founded_year = 1990, revision = 2, updated_at = current_timestamp WHERE company_cd = '00001';
+DELETE FROM company WHERE company_cd = '00002';`
+
+	e := New(conn)
+	r := DeltaRequest{
+		TargetBookPath: filepath.Join("testdata", "compare_delta.xlsx"),
+		IgnoreColumns:  []string{"created_at", "updated_at"},
+	}
+
+	t.Run("expected changes", func(t *testing.T) {
+		if _, err := conn.Exec(seed); err != nil {
+			t.Fatal(err)
+		}
+		s, err := e.Snapshot(context.Background(), "company")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := conn.Exec(change); err != nil {
+			t.Fatal(err)
+		}
+		if !e.CompareDelta(t, s, r) {
+			t.Error("CompareDelta() should return true but false")
+		}
+	})
+
+	t.Run("unexpected changes", func(t *testing.T) {
+		if _, err := conn.Exec(seed); err != nil {
+			t.Fatal(err)
+		}
+		s, err := e.Snapshot(context.Background(), "company")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := conn.Exec(change + `
+UPDATE company SET company_name = 'Future2' WHERE company_cd = '00004';`); err != nil {
+			t.Fatal(err)
+		}
+
+		res, err := e.DiffDelta(context.Background(), s, r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []Row{{Op: "~", Cells: []Cell{{Column: "company_cd", Value: "00004"}, {Column: "company_name", Value: "Future2"}}}}
+		if len(res.Tables) != 1 {
+			t.Fatalf("DiffDelta() returns %d tables, want 1", len(res.Tables))
+		}
+		if diff := cmp.Diff(want, res.Tables[0].ExtraRows); diff != "" {
+			t.Errorf("DiffDelta() mismatch (-want +got):\n%s", diff)
+		}
+	})
+}
+
+func Test_detectChanges(t *testing.T) {
+	tests := []struct {
+		name          string
+		before, after []compareRow
+		keys          []string
+		want          rowChanges
+	}{
+		{
+			name:   "by key",
+			before: deltaRows([]string{"1", "a"}, []string{"2", "b"}, []string{"3", "c"}),
+			after:  deltaRows([]string{"1", "a"}, []string{"2", "B"}, []string{"4", "d"}),
+			keys:   []string{"id"},
+			want: rowChanges{
+				inserted: deltaRows([]string{"4", "d"}),
+				deleted:  deltaRows([]string{"3", "c"}),
+				updated:  [][2]compareRow{{deltaRows([]string{"2", "b"})[0], deltaRows([]string{"2", "B"})[0]}},
+			},
+		},
+		{
+			name:   "without key",
+			before: deltaRows([]string{"1", "a"}, []string{"1", "a"}),
+			after:  deltaRows([]string{"1", "a"}, []string{"1", "b"}),
+			want: rowChanges{
+				inserted: deltaRows([]string{"1", "b"}),
+				deleted:  deltaRows([]string{"1", "a"}),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := detectChanges(tt.before, tt.after, tt.keys)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.want, got, cmp.AllowUnexported(rowChanges{}, compareRow{}, x{}), cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("detectChanges() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_diffDelta(t *testing.T) {
+	ch := rowChanges{
+		inserted: deltaRows([]string{"4", "d"}),
+		deleted:  deltaRows([]string{"3", "c"}),
+		updated:  [][2]compareRow{{deltaRows([]string{"2", "b"})[0], deltaRows([]string{"2", "B"})[0]}},
+	}
+	withNum := func(num int, rs []compareRow) []compareRow {
+		rs[0].num = num
+		return rs
+	}
+
+	tests := []struct {
+		name    string
+		want    map[string][]compareRow
+		wantErr bool
+		diff    *TableDiff
+	}{
+		{
+			name: "all changes are expected",
+			want: map[string][]compareRow{
+				deltaOpInsert: withNum(1, deltaRows([]string{"4", "d"})),
+				deltaOpDelete: withNum(2, deltaRows([]string{"3", "c"})),
+				deltaOpUpdate: withNum(3, deltaRows([]string{"2", "B"})),
+			},
+			diff: &TableDiff{},
+		},
+		{
+			name: "update only key column",
+			want: map[string][]compareRow{
+				deltaOpInsert: withNum(1, deltaRows([]string{"4", "d"})),
+				deltaOpDelete: withNum(2, deltaRows([]string{"3", "c"})),
+				deltaOpUpdate: withNum(3, projectRows(deltaRows([]string{"2", "B"}), []string{"id"})),
+			},
+			diff: &TableDiff{
+				ChangedCells: []CellDiff{{Row: 3, Column: "name", Want: "b", Got: "B"}},
+			},
+		},
+		{
+			name: "no expected changes",
+			want: nil,
+			diff: &TableDiff{
+				ExtraRows: []Row{
+					{Op: "+", Cells: []Cell{{Column: "id", Value: "4"}, {Column: "name", Value: "d"}}},
+					{Op: "-", Cells: []Cell{{Column: "id", Value: "3"}, {Column: "name", Value: "c"}}},
+					{Op: "~", Cells: []Cell{{Column: "id", Value: "2"}, {Column: "name", Value: "B"}}},
+				},
+			},
+		},
+		{
+			name: "expected changes did not happen",
+			want: map[string][]compareRow{
+				deltaOpInsert: withNum(1, deltaRows([]string{"4", "d"})),
+				deltaOpDelete: withNum(2, deltaRows([]string{"3", "c"})),
+				deltaOpUpdate: append(withNum(3, deltaRows([]string{"2", "B"})), compareRow{num: 4, values: deltaRows([]string{"1", "A"})[0].values}),
+			},
+			diff: &TableDiff{
+				MissingRows: []Row{{Row: 4, Op: "~", Cells: []Cell{{Column: "id", Value: "1"}, {Column: "name", Value: "A"}}}},
+			},
+		},
+		{
+			name: "update without key column",
+			want: map[string][]compareRow{
+				deltaOpUpdate: projectRows(deltaRows([]string{"2", "B"}), []string{"name"}),
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := diffDelta(ch, tt.want, []string{"id"})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("diffDelta() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if diff := cmp.Diff(tt.diff, got, cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("diffDelta() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// deltaRows は id, name カラムの行を返します
+func deltaRows(vs ...[]string) []compareRow {
+	rows := make([]compareRow, 0, len(vs))
+	for _, v := range vs {
+		rows = append(rows, compareRow{values: []x{{column: "id", value: v[0]}, {column: "name", value: v[1]}}})
+	}
+	return rows
+}
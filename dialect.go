@@ -0,0 +1,42 @@
+package exceltesting
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Dialect はデータベースのSQL方言です
+type Dialect string
+
+const (
+	// DialectPostgres はPostgreSQLです
+	DialectPostgres Dialect = "postgres"
+	// DialectMySQL はMySQLです
+	DialectMySQL Dialect = "mysql"
+)
+
+// detectDialect はデータベースドライバからSQL方言を判定します。判定できない場合はPostgreSQLとみなします。
+func detectDialect(db *sql.DB) Dialect {
+	if strings.Contains(strings.ToLower(fmt.Sprintf("%T", db.Driver())), "mysql") {
+		return DialectMySQL
+	}
+	return DialectPostgres
+}
+
+// beginStatement はトランザクションを開始するステートメントを返します
+func (d Dialect) beginStatement() string {
+	if d == DialectMySQL {
+		return "START TRANSACTION;"
+	}
+	return "BEGIN;"
+}
+
+// placeholder は i 番目 (1始まり) のパラメータのプレースホルダを返します
+func (d Dialect) placeholder(i int) string {
+	if d == DialectMySQL {
+		return "?"
+	}
+	return "$" + strconv.Itoa(i)
+}
@@ -0,0 +1,290 @@
+package exceltesting
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// savepointDiffDB は DiffDB でテーブル単位の失敗を後続のテーブルに影響させないためのセーブポイントです
+const savepointDiffDB = "exceltesting_diff_db"
+
+// DiffDBRequest は2つのデータベースのテーブルを比較するための設定です
+type DiffDBRequest struct {
+	// Tables は比較するテーブル名です
+	Tables []string
+	// SourceSchema, TargetSchema は比較元、比較先のテーブルのスキーマです (MySQLの場合はデータベース)。
+	// 指定しない場合は接続先のデフォルトのスキーマのテーブルを比較します
+	SourceSchema string
+	TargetSchema string
+	// 無視するカラム名
+	IgnoreColumns []string
+	// KeyColumns はテーブル名ごとに、行を特定するカラム名を指定します。
+	// 指定しない場合は比較元のテーブルの主キーや一意インデックスで突き合わせます
+	KeyColumns map[string][]string
+	// Filters はテーブル名ごとに、比較対象の行を絞り込むWHERE句の条件を指定します
+	Filters map[string]string
+	// TimePrecision は日時を比較する精度です (e.g. time.Millisecond)。0の場合は切り捨てずに比較します
+	TimePrecision time.Duration
+	// Timeout は比較全体のタイムアウトです。0の場合はタイムアウトしません
+	Timeout time.Duration
+	// ReportPath を指定すると、差分がある場合に比較元の行をシートに書き込み、
+	// 差分のあるセルや行を強調したBookを保存します
+	ReportPath string
+}
+
+// DiffDB は New で指定したデータベースを比較元、target を比較先として、テーブルの値を比較します。
+// 行の突き合わせや値の正規化は Diff と同様です。
+//
+// 差分は比較元を期待値とみなして報告します。比較元にのみ存在する行は TableDiff.MissingRows 、
+// 比較先にのみ存在する行は TableDiff.ExtraRows です。行の番号は比較元の行の順番です。
+func (e *exceltesing) DiffDB(ctx context.Context, target *sql.DB, r DiffDBRequest) (*CompareResult, error) {
+	ctx, cancel := withTimeout(ctx, r.Timeout)
+	defer cancel()
+
+	dst := New(target)
+	srcTx, restoreSrc, err := e.beginDiffDB(ctx, r.SourceSchema)
+	if err != nil {
+		return nil, fmt.Errorf("exceltesting: source: %w", err)
+	}
+	defer srcTx.Rollback()
+	defer restoreSrc()
+	dstTx, restoreDst, err := dst.beginDiffDB(ctx, r.TargetSchema)
+	if err != nil {
+		return nil, fmt.Errorf("exceltesting: target: %w", err)
+	}
+	defer dstTx.Rollback()
+	defer restoreDst()
+
+	req := &CompareRequest{
+		IgnoreColumns: r.IgnoreColumns,
+		KeyColumns:    r.KeyColumns,
+		Filters:       r.Filters,
+		TimePrecision: r.TimePrecision,
+	}
+
+	res := &CompareResult{}
+	var sources []*table
+	for _, name := range r.Tables {
+		var src *table
+		err := inSavepoint(ctx, srcTx, savepointDiffDB, func() error {
+			return inSavepoint(ctx, dstTx, savepointDiffDB, func() error {
+				d, t, err := e.diffDBTable(ctx, srcTx, dst, dstTx, name, req)
+				if err != nil {
+					return err
+				}
+				res.Tables = append(res.Tables, *d)
+				src = t
+				return nil
+			})
+		})
+		if err != nil {
+			res.Tables = append(res.Tables, TableDiff{
+				Table: name,
+				Sheet: name,
+				Err:   fmt.Errorf("exceltesting: failed to diff table %s: %w", name, err),
+			})
+			continue
+		}
+		sources = append(sources, src)
+	}
+
+	if r.ReportPath != "" && !res.Equal() {
+		if err := writeDiffDBReport(r.ReportPath, sources, res); err != nil {
+			return nil, fmt.Errorf("exceltesting: failed to write report: %w", err)
+		}
+	}
+	return res, nil
+}
+
+// beginDiffDB は比較に利用する読み取り専用のトランザクションを開始します。
+// restore はトランザクションの終了前に呼び出し、変更したスキーマを元に戻します。
+func (e *exceltesing) beginDiffDB(ctx context.Context, schema string) (tx *sql.Tx, restore func() error, err error) {
+	tx, err = e.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	restore, err = useSchema(ctx, tx, detectDialect(e.db), schema)
+	if err != nil {
+		_ = restore()
+		_ = tx.Rollback()
+		return nil, nil, err
+	}
+	return tx, restore, nil
+}
+
+// diffDBTable は比較元と比較先のテーブル name の差分と、レポートに書き込む比較元の行を返します
+func (e *exceltesing) diffDBTable(ctx context.Context, srcTx *sql.Tx, dst *exceltesing, dstTx *sql.Tx, name string, req *CompareRequest) (*TableDiff, *table, error) {
+	mt, err := e.rowMatching(ctx, srcTx, name, req)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	columns, err := queryColumns(ctx, srcTx, name)
+	if err != nil {
+		return nil, nil, fmt.Errorf("get columns: %w", err)
+	}
+	t := &table{sheet: name, name: name, columns: columns}
+
+	query, cs, err := e.buildComparingQuery(t, mt.orderClause(), req)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	opt := normalizeOption{timePrecision: req.TimePrecision}
+	srcData, srcTypes, err := e.getComparingData(ctx, srcTx, query, len(cs))
+	if err != nil {
+		return nil, nil, fmt.Errorf("source: %w", err)
+	}
+	dstData, dstTypes, err := dst.getComparingData(ctx, dstTx, query, len(cs))
+	if err != nil {
+		return nil, nil, fmt.Errorf("target: %w", err)
+	}
+
+	want := convert(srcData, cs, srcTypes, opt)
+	nums := make([]int, len(want))
+	for i := range nums {
+		nums[i] = i + 1
+	}
+
+	d, err := mt.diff(toCompareRows(want, nums), toCompareRows(convert(dstData, cs, dstTypes, opt), nil))
+	if err != nil {
+		return nil, nil, err
+	}
+	d.Table = name
+	d.Sheet = name
+
+	// レポートには比較したカラムと比較元の値を書き込む
+	t.columns = cs
+	t.data = make([][]string, len(want))
+	for i, w := range want {
+		for _, v := range w {
+			t.data[i] = append(t.data[i], v.value)
+		}
+	}
+	return d, t, nil
+}
+
+// queryColumns はテーブルのカラム名を定義順に返します
+func queryColumns(ctx context.Context, q queryer, tableName string) ([]string, error) {
+	rows, err := q.QueryContext(ctx, fmt.Sprintf("SELECT * FROM %s WHERE 1 = 0", tableName))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return rows.Columns()
+}
+
+// inSavepoint はセーブポイント内で fn を実行し、失敗した場合はセーブポイントまでロールバックします
+func inSavepoint(ctx context.Context, tx *sql.Tx, name string, fn func() error) error {
+	if _, err := tx.ExecContext(ctx, "SAVEPOINT "+name); err != nil {
+		return fmt.Errorf("savepoint: %w", err)
+	}
+	if err := fn(); err != nil {
+		_, _ = tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+name)
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "RELEASE SAVEPOINT "+name); err != nil {
+		return fmt.Errorf("release savepoint: %w", err)
+	}
+	return nil
+}
+
+// writeDiffDBReport は比較元の行をバージョン2.0形式のシートに書き込み、差分を強調したBookを path に保存します
+func writeDiffDBReport(path string, sources []*table, res *CompareResult) error {
+	f := excelize.NewFile()
+	defer f.Close()
+
+	for _, t := range sources {
+		if err := writeTableSheet(f, t); err != nil {
+			return fmt.Errorf("sheet = %s: %w", t.sheet, err)
+		}
+	}
+	if len(sources) > 0 {
+		f.DeleteSheet("Sheet1")
+	}
+
+	if err := annotate(f, res); err != nil {
+		return err
+	}
+	if err := f.SaveAs(path); err != nil {
+		return fmt.Errorf("save report: %w", err)
+	}
+	return nil
+}
+
+// writeTableSheet はテーブル t のカラムと値をバージョン2.0形式のシートとして書き込みます
+func writeTableSheet(f *excelize.File, t *table) error {
+	f.NewSheet(t.sheet)
+
+	var (
+		border = []excelize.Border{
+			{Type: "top", Style: 1, Color: "000000"},
+			{Type: "left", Style: 1, Color: "000000"},
+			{Type: "right", Style: 1, Color: "000000"},
+			{Type: "bottom", Style: 1, Color: "000000"},
+		}
+		// dump コマンドと同じ書式にする
+		rowHeaderStyle, _ = f.NewStyle(&excelize.Style{
+			Border: border,
+			Fill:   excelize.Fill{Type: "pattern", Color: []string{"#D9D9D9"}, Pattern: 1},
+		})
+		columnHeaderStyle, _ = f.NewStyle(&excelize.Style{
+			Border: border,
+			Fill:   excelize.Fill{Type: "pattern", Color: []string{"#FCD5B4"}, Pattern: 1},
+		})
+		valueStyle, _ = f.NewStyle(&excelize.Style{
+			NumFmt: 49, // Text
+			Border: border,
+		})
+	)
+
+	cells := map[string]string{
+		"A1": t.sheet,
+		"A2": t.name,
+		"A3": "version",
+		"B3": "2.0",
+		"A5": "項目名",
+		"A6": "項目物理名",
+	}
+	for cell, v := range cells {
+		if err := f.SetCellValue(t.sheet, cell, v); err != nil {
+			return err
+		}
+	}
+
+	for j, c := range t.columns {
+		for _, row := range []int{5, 6} {
+			cell, _ := excelize.CoordinatesToCellName(j+2, row)
+			if err := f.SetCellValue(t.sheet, cell, c); err != nil {
+				return err
+			}
+		}
+	}
+	_ = f.SetCellStyle(t.sheet, "A5", "A6", rowHeaderStyle)
+	if len(t.columns) > 0 {
+		last, _ := excelize.CoordinatesToCellName(len(t.columns)+1, 6)
+		_ = f.SetCellStyle(t.sheet, "B5", last, columnHeaderStyle)
+	}
+
+	for i, row := range t.data {
+		line := 7 + i
+		first, _ := excelize.CoordinatesToCellName(1, line)
+		if err := f.SetCellValue(t.sheet, first, strconv.Itoa(i+1)); err != nil {
+			return err
+		}
+		for j, v := range row {
+			cell, _ := excelize.CoordinatesToCellName(j+2, line)
+			if err := f.SetCellValue(t.sheet, cell, v); err != nil {
+				return err
+			}
+		}
+		last, _ := excelize.CoordinatesToCellName(len(t.columns)+1, line)
+		_ = f.SetCellStyle(t.sheet, first, last, valueStyle)
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package exceltesting
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/fc-shota-miyazaki/go-exceltesting/testonly"
+	"github.com/google/go-cmp/cmp"
+	"github.com/xuri/excelize/v2"
+)
+
+func Test_exceltesing_DiffDB(t *testing.T) {
+	conn := testonly.OpenTestDB(t)
+	defer conn.Close()
+
+	testonly.ExecSQLFile(t, conn, filepath.Join("testdata", "schema", "ddl.sql"))
+
+	if _, err := conn.Exec(`DROP SCHEMA IF EXISTS exceltesting_diff CASCADE;
+CREATE SCHEMA exceltesting_diff;
+CREATE TABLE exceltesting_diff.company (LIKE public.company INCLUDING ALL);
+TRUNCATE company;
+INSERT INTO company (company_cd,company_name,founded_year,created_at,updated_at,revision) VALUES ('00001','Future',1989,current_timestamp,current_timestamp,1),('00002','YDC',1972,current_timestamp,current_timestamp,1);
+INSERT INTO exceltesting_diff.company (company_cd,company_name,founded_year,created_at,updated_at,revision) VALUES ('00001','Future',1989,current_timestamp,current_timestamp,2),('00003','FutureOne',2002,current_timestamp,current_timestamp,1);`); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_, _ = conn.Exec(`DROP SCHEMA IF EXISTS exceltesting_diff CASCADE;`)
+	})
+
+	e := New(conn)
+	res, err := e.DiffDB(context.Background(), conn, DiffDBRequest{
+		Tables:        []string{"company", "not_exists"},
+		TargetSchema:  "exceltesting_diff",
+		IgnoreColumns: []string{"created_at", "updated_at"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := TableDiff{
+		Table: "company",
+		Sheet: "company",
+		MissingRows: []Row{
+			{Row: 2, Cells: []Cell{{Column: "company_cd", Value: "00002"}, {Column: "company_name", Value: "YDC"}, {Column: "founded_year", Value: "1972"}, {Column: "revision", Value: "1"}}},
+		},
+		ExtraRows: []Row{
+			{Cells: []Cell{{Column: "company_cd", Value: "00003"}, {Column: "company_name", Value: "FutureOne"}, {Column: "founded_year", Value: "2002"}, {Column: "revision", Value: "1"}}},
+		},
+		ChangedCells: []CellDiff{
+			{Row: 1, Column: "revision", Want: "1", Got: "2"},
+		},
+	}
+	if len(res.Tables) != 2 {
+		t.Fatalf("DiffDB() returns %d tables, want 2", len(res.Tables))
+	}
+	if diff := cmp.Diff(want, res.Tables[0]); diff != "" {
+		t.Errorf("DiffDB() mismatch (-want +got):\n%s", diff)
+	}
+	if res.Tables[1].Err == nil {
+		t.Error("DiffDB() should return error for table which does not exist")
+	}
+}
+
+func Test_writeDiffDBReport(t *testing.T) {
+	sources := []*table{
+		{
+			sheet:   "company",
+			name:    "company",
+			columns: []string{"company_cd", "company_name"},
+			data:    [][]string{{"00001", "Future"}, {"00002", "YDC"}},
+		},
+	}
+	res := &CompareResult{Tables: []TableDiff{{
+		Table:        "company",
+		Sheet:        "company",
+		MissingRows:  []Row{{Row: 2, Cells: []Cell{{Column: "company_cd", Value: "00002"}}}},
+		ChangedCells: []CellDiff{{Row: 1, Column: "company_name", Want: "Future", Got: "future"}},
+	}}}
+
+	path := filepath.Join(t.TempDir(), "report.xlsx")
+	if err := writeDiffDBReport(path, sources, res); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := excelize.OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if diff := cmp.Diff([]string{"company"}, f.GetSheetList()); diff != "" {
+		t.Errorf("sheets mismatch (-want +got):\n%s", diff)
+	}
+
+	// 比較元の行はバージョン2.0形式のシートとして読み込める
+	e := &exceltesing{}
+	got, err := e.loadExcelSheet(f, "company")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(sources[0].data, got.data); diff != "" {
+		t.Errorf("data mismatch (-want +got):\n%s", diff)
+	}
+
+	var comments []string
+	for _, c := range f.GetComments()["company"] {
+		comments = append(comments, c.Ref+": "+c.Text)
+	}
+	wantComments := []string{
+		"C7: exceltesting: got: future",
+		"A8: exceltesting: missing in database",
+	}
+	if diff := cmp.Diff(wantComments, comments); diff != "" {
+		t.Errorf("comments mismatch (-want +got):\n%s", diff)
+	}
+}
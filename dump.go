@@ -0,0 +1,464 @@
+package exceltesting
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/xuri/excelize/v2"
+	"golang.org/x/exp/slices"
+)
+
+// DumpOptions はデータベースのテーブルをExcelのBookに出力するための設定です
+type DumpOptions struct {
+	// 出力するExcelパス
+	TargetBookPath string
+	// Tables は出力するテーブル名です。指定しない場合はスキーマのすべてのテーブルを出力します
+	Tables []string
+	// SystemColumns は他のカラムと書式を区別するカラム名です (e.g. created_at, updated_at, revision)
+	SystemColumns []string
+	// Limit はテーブルごとに出力する行数の上限です。0の場合は制限しません
+	Limit int
+	// IncludePartitions はパーティションもテーブルとして出力します (PostgreSQLのみ)
+	IncludePartitions bool
+	// Where はテーブル名ごとに、出力する行を絞り込むWHERE句の条件を指定します (e.g. "created_at > now() - interval '1 day'")
+	Where map[string]string
+	// OrderBy はテーブル名ごとに、行の並び順を指定します。指定しない場合は主キーや一意インデックスの順に出力します
+	OrderBy map[string][]string
+	// Queries はSELECT文の結果を、クエリごとのシートに出力します。Limit は適用しません
+	Queries []DumpQuery
+	// Roots を指定すると、起点の行と FollowFKs により外部キーでたどった行のみを出力します。
+	// Tables, Where, OrderBy, Limit は適用しません
+	Roots []DumpRoot
+	// FollowFKs は起点の行から外部キーをたどります。参照先の行は投入できるよう深さに関係なくたどり、
+	// 参照元の行は起点から FKDepth の深さまでたどります
+	FollowFKs bool
+	// FKDepth は起点の行から参照元の行をたどる深さです
+	FKDepth int
+}
+
+// DumpRoot は部分的に出力する行の起点です
+type DumpRoot struct {
+	// Table はテーブル名です
+	Table string
+	// Where は起点の行を特定するWHERE句の条件です (e.g. "id = 123")
+	Where string
+}
+
+// DumpQuery はSELECT文の結果を出力するシートです
+type DumpQuery struct {
+	// Name はシート名です
+	Name string
+	// Query はSELECT文です。出力したシートは比較でクエリシートとして利用できます
+	Query string
+}
+
+// tableDef はダンプするテーブルの定義です
+type tableDef struct {
+	name    string
+	comment string
+	columns []columnDef
+}
+
+// columnDef はダンプするカラムの定義です
+type columnDef struct {
+	name     string
+	comment  string
+	dataType string
+}
+
+// foreignKey は外部キーの定義です。columns は参照元の table のカラム、refColumns は参照先の refTable のカラムです
+type foreignKey struct {
+	name       string
+	table      string
+	columns    []string
+	refTable   string
+	refColumns []string
+}
+
+// schemaIntrospector はダイアレクトごとにスキーマからテーブルとカラムの定義を取得します
+type schemaIntrospector interface {
+	// tableDefs はスキーマのテーブルとカラムの定義をテーブル名の順に返します
+	tableDefs(ctx context.Context, q queryer, opt DumpOptions) ([]tableDef, error)
+	// foreignKeys はスキーマの外部キーを参照元のテーブル名の順に返します
+	foreignKeys(ctx context.Context, q queryer) ([]foreignKey, error)
+}
+
+// introspector はダイアレクトに応じた schemaIntrospector を返します
+func (d Dialect) introspector() schemaIntrospector {
+	if d == DialectMySQL {
+		return mysqlIntrospector{}
+	}
+	return postgresIntrospector{}
+}
+
+// postgresIntrospector は pg_class と information_schema から定義を取得します。
+// テーブルとカラムのコメントを含みます。
+type postgresIntrospector struct{}
+
+func (postgresIntrospector) tableDefs(ctx context.Context, q queryer, opt DumpOptions) ([]tableDef, error) {
+	return scanTableDefs(ctx, q, getDumpTableDefs, opt.IncludePartitions)
+}
+
+func (postgresIntrospector) foreignKeys(ctx context.Context, q queryer) ([]foreignKey, error) {
+	return scanForeignKeys(ctx, q, getForeignKeys)
+}
+
+// mysqlIntrospector は information_schema から定義を取得します。MySQLのパーティションはテーブルとして扱いません
+type mysqlIntrospector struct{}
+
+func (mysqlIntrospector) tableDefs(ctx context.Context, q queryer, _ DumpOptions) ([]tableDef, error) {
+	return scanTableDefs(ctx, q, getDumpTableDefsMySQL)
+}
+
+func (mysqlIntrospector) foreignKeys(ctx context.Context, q queryer) ([]foreignKey, error) {
+	return scanForeignKeys(ctx, q, getForeignKeysMySQL)
+}
+
+// scanTableDefs はテーブル名、テーブルのコメント、カラム名、カラムのコメント、型の順の行をテーブルごとにまとめます
+func scanTableDefs(ctx context.Context, q queryer, query string, args ...any) ([]tableDef, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var defs []tableDef
+	for rows.Next() {
+		var tableName, tableComment, columnName, columnComment, dataType sql.NullString
+		if err := rows.Scan(&tableName, &tableComment, &columnName, &columnComment, &dataType); err != nil {
+			return nil, err
+		}
+		if len(defs) == 0 || defs[len(defs)-1].name != tableName.String {
+			defs = append(defs, tableDef{name: tableName.String, comment: tableComment.String})
+		}
+		if !columnName.Valid {
+			continue
+		}
+		d := &defs[len(defs)-1]
+		d.columns = append(d.columns, columnDef{name: columnName.String, comment: columnComment.String, dataType: dataType.String})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return defs, nil
+}
+
+// scanForeignKeys は制約名、参照元テーブル、参照先テーブル、カンマ区切りの参照元カラム、参照先カラムの順の行を読み込みます
+func scanForeignKeys(ctx context.Context, q queryer, query string) ([]foreignKey, error) {
+	rows, err := q.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var fks []foreignKey
+	for rows.Next() {
+		var fk foreignKey
+		var columns, refColumns string
+		if err := rows.Scan(&fk.name, &fk.table, &fk.refTable, &columns, &refColumns); err != nil {
+			return nil, err
+		}
+		fk.columns = strings.Split(columns, ",")
+		fk.refColumns = strings.Split(refColumns, ",")
+		fks = append(fks, fk)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return fks, nil
+}
+
+// dumpTable はシートに出力するテーブルの定義と行です
+type dumpTable struct {
+	def     tableDef
+	records [][]any
+}
+
+// Dump はデータベース db のテーブルの定義と行を、テーブルごとのバージョン2.0形式のシートとして出力します。
+// シート名はテーブルのコメント、コメントがない場合はテーブル名です。
+// 出力したBookは値を編集して Load や Compare の期待値として利用できます。
+func Dump(ctx context.Context, db *sql.DB, opt DumpOptions) error {
+	defs, err := detectDialect(db).introspector().tableDefs(ctx, db, opt)
+	if err != nil {
+		return fmt.Errorf("exceltesting: failed to get table definitions: %w", err)
+	}
+
+	e := New(db)
+	var tables []dumpTable
+	if len(opt.Roots) > 0 {
+		tables, err = e.collectSubset(ctx, defs, opt)
+	} else {
+		tables, err = e.selectTables(ctx, defs, opt)
+	}
+	if err != nil {
+		return fmt.Errorf("exceltesting: %w", err)
+	}
+
+	for _, q := range opt.Queries {
+		d, records, err := queryDumpRecords(ctx, db, q)
+		if err != nil {
+			return fmt.Errorf("exceltesting: failed to query %s: %w", q.Name, err)
+		}
+		tables = append(tables, dumpTable{def: d, records: records})
+	}
+
+	f := excelize.NewFile()
+	defer f.Close()
+
+	styles := newDumpStyles(f)
+	for i, t := range tables {
+		sheet := writeDumpSheet(f, styles, t.def, t.records, opt.SystemColumns)
+		if i == 0 {
+			f.SetActiveSheet(f.GetSheetIndex(sheet))
+		}
+	}
+	f.DeleteSheet("Sheet1")
+
+	if err := f.SaveAs(opt.TargetBookPath); err != nil {
+		return fmt.Errorf("exceltesting: failed to save dump result: %w", err)
+	}
+	return nil
+}
+
+// selectTables は opt.Tables のテーブル、指定がない場合はすべてのテーブルの行を取得します
+func (e *exceltesing) selectTables(ctx context.Context, defs []tableDef, opt DumpOptions) ([]dumpTable, error) {
+	if len(opt.Tables) > 0 {
+		filtered := defs[:0]
+		for _, d := range defs {
+			if slices.Contains(opt.Tables, d.name) {
+				filtered = append(filtered, d)
+			}
+		}
+		defs = filtered
+	}
+	if len(defs) == 0 && len(opt.Queries) == 0 {
+		return nil, errors.New("table not found")
+	}
+	if err := validateDumpOptions(defs, opt); err != nil {
+		return nil, err
+	}
+
+	tables := make([]dumpTable, 0, len(defs))
+	for _, d := range defs {
+		orderBy, err := e.dumpOrder(ctx, d.name, opt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get primary key of %s: %w", d.name, err)
+		}
+		records, err := selectDumpRecords(ctx, e.db, buildDumpQuery(d, opt.Where[d.name], orderBy, opt.Limit), len(d.columns))
+		if err != nil {
+			return nil, fmt.Errorf("failed to select records of %s: %w", d.name, err)
+		}
+		tables = append(tables, dumpTable{def: d, records: records})
+	}
+	return tables, nil
+}
+
+// validateDumpOptions はテーブルごとの設定が、出力するテーブルを指定しているか検証します
+func validateDumpOptions(defs []tableDef, opt DumpOptions) error {
+	dumped := func(name string) bool {
+		return slices.IndexFunc(defs, func(d tableDef) bool { return d.name == name }) >= 0
+	}
+	for name := range opt.Where {
+		if !dumped(name) {
+			return fmt.Errorf("where: table %s is not dumped", name)
+		}
+	}
+	for name := range opt.OrderBy {
+		if !dumped(name) {
+			return fmt.Errorf("order by: table %s is not dumped", name)
+		}
+	}
+	for _, q := range opt.Queries {
+		if !isQuery(q.Query) {
+			return fmt.Errorf("query %s must be a SELECT statement", q.Name)
+		}
+	}
+	return nil
+}
+
+// dumpOrder はテーブル name の行の並び順を返します。
+// 指定がない場合は主キーや一意インデックスの順で、いずれもない場合は空です。
+func (e *exceltesing) dumpOrder(ctx context.Context, name string, opt DumpOptions) ([]string, error) {
+	if cs := opt.OrderBy[name]; len(cs) > 0 {
+		return cs, nil
+	}
+	pk, err := e.getPrimaryKeyColumns(ctx, e.db, name)
+	if errors.Is(err, errPrimaryKeyNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return strings.Split(pk, ","), nil
+}
+
+// buildDumpQuery はテーブル d の行を取得するSELECT文を作成します。limit が0の場合は制限しません
+func buildDumpQuery(d tableDef, where string, orderBy []string, limit int) string {
+	columns := make([]string, 0, len(d.columns))
+	for _, c := range d.columns {
+		columns = append(columns, c.name)
+	}
+	query := fmt.Sprintf("SELECT %s FROM %s", strings.Join(columns, ", "), d.name)
+	if where = strings.TrimSpace(where); where != "" {
+		query += " WHERE " + where
+	}
+	if len(orderBy) > 0 {
+		query += " ORDER BY " + strings.Join(orderBy, ", ")
+	}
+	if limit > 0 {
+		query += " LIMIT " + strconv.Itoa(limit)
+	}
+	return query
+}
+
+// selectDumpRecords は query の結果の行を取得します。n はカラム数です
+func selectDumpRecords(ctx context.Context, q queryer, query string, n int, args ...any) ([][]any, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanDumpRecords(rows, n)
+}
+
+// queryDumpRecords はSELECT文の結果のカラムと行を取得します。
+// シートのA2セルにSELECT文を出力し、比較でクエリシートとして読み込めるようにします。
+func queryDumpRecords(ctx context.Context, q queryer, dq DumpQuery) (tableDef, [][]any, error) {
+	query := strings.TrimSuffix(strings.TrimSpace(dq.Query), ";")
+	rows, err := q.QueryContext(ctx, query)
+	if err != nil {
+		return tableDef{}, nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return tableDef{}, nil, err
+	}
+	d := tableDef{name: query, comment: dq.Name}
+	for _, c := range columns {
+		d.columns = append(d.columns, columnDef{name: c})
+	}
+	records, err := scanDumpRecords(rows, len(columns))
+	if err != nil {
+		return tableDef{}, nil, err
+	}
+	return d, records, nil
+}
+
+func scanDumpRecords(rows *sql.Rows, n int) ([][]any, error) {
+	var records [][]any
+	for rows.Next() {
+		g := make([]any, n)
+		for i := range g {
+			g[i] = &g[i]
+		}
+		if err := rows.Scan(g...); err != nil {
+			return nil, err
+		}
+		records = append(records, g)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// dumpStyles はダンプするシートのセルの書式です
+type dumpStyles struct {
+	rowHeader          int
+	columnHeader       int
+	columnHeaderSystem int
+	row                int
+	// valueText は値セルを文字列として扱うための書式です
+	valueText int
+}
+
+func newDumpStyles(f *excelize.File) dumpStyles {
+	border := []excelize.Border{
+		{Type: "top", Style: 1, Color: "000000"},
+		{Type: "left", Style: 1, Color: "000000"},
+		{Type: "right", Style: 1, Color: "000000"},
+		{Type: "bottom", Style: 1, Color: "000000"},
+	}
+	var s dumpStyles
+	s.rowHeader, _ = f.NewStyle(&excelize.Style{Border: border, Fill: excelize.Fill{Type: "pattern", Color: []string{"#D9D9D9"}, Pattern: 1}})
+	s.columnHeader, _ = f.NewStyle(&excelize.Style{Border: border, Fill: excelize.Fill{Type: "pattern", Color: []string{"#FCD5B4"}, Pattern: 1}})
+	s.columnHeaderSystem, _ = f.NewStyle(&excelize.Style{Border: border, Fill: excelize.Fill{Type: "pattern", Color: []string{"#BFBFBF"}, Pattern: 1}})
+	s.row, _ = f.NewStyle(&excelize.Style{Border: border})
+	s.valueText, _ = f.NewStyle(&excelize.Style{NumFmt: 49, Border: border}) // 49 is Text
+	return s
+}
+
+// writeDumpSheet はテーブル d の定義と行 records をシートに書き込み、シート名を返します。
+// シート名はテーブルのコメント、コメントがない場合はテーブル名です
+func writeDumpSheet(f *excelize.File, s dumpStyles, d tableDef, records [][]any, systemColumns []string) string {
+	sheet := d.comment
+	if len(sheet) == 0 {
+		sheet = d.name
+	}
+	f.NewSheet(sheet)
+
+	_ = f.SetCellValue(sheet, "A1", d.comment)
+	_ = f.SetCellValue(sheet, "A2", d.name)
+	_ = f.SetCellValue(sheet, "A3", "version")
+	_ = f.SetCellValue(sheet, "B3", "2.0")
+	_ = f.SetCellValue(sheet, "A5", "項目名")
+	_ = f.SetCellValue(sheet, "A6", "項目物理名")
+	_ = f.SetColWidth(sheet, "A", "A", 12.86)
+	_ = f.SetCellStyle(sheet, "A5", "A6", s.rowHeader)
+
+	for i, c := range d.columns {
+		axisComment, _ := excelize.CoordinatesToCellName(2+i, 5)
+		axisName, _ := excelize.CoordinatesToCellName(2+i, 6)
+		_ = f.SetCellValue(sheet, axisComment, c.comment)
+		_ = f.SetCellValue(sheet, axisName, c.name)
+
+		width := utf8.RuneCountInString(c.comment) * 2
+		if width < utf8.RuneCountInString(c.name) {
+			width = utf8.RuneCountInString(c.name)
+		}
+		col, _ := excelize.ColumnNumberToName(2 + i)
+		_ = f.SetColWidth(sheet, col, col, float64(width+2)) // + 2 for margin
+
+		style := s.columnHeader
+		if slices.Contains(systemColumns, c.name) {
+			style = s.columnHeaderSystem
+		}
+		_ = f.SetCellStyle(sheet, axisComment, axisName, style)
+	}
+
+	// データがない場合も記入できるよう、少なくとも6行分の枠線を設定する
+	lastLine := 12
+	if l := 6 + len(records); l > lastLine {
+		lastLine = l
+	}
+	last, _ := excelize.CoordinatesToCellName(1+len(d.columns), lastLine)
+	_ = f.SetCellStyle(sheet, "A7", last, s.row)
+
+	for i, record := range records {
+		line := 7 + i
+		first, _ := excelize.CoordinatesToCellName(1, line)
+		_ = f.SetCellValue(sheet, first, strconv.Itoa(i+1))
+		for j, v := range record {
+			cell, _ := excelize.CoordinatesToCellName(j+2, line)
+			_ = f.SetCellValue(sheet, cell, dumpCellValue(v))
+			_ = f.SetCellStyle(sheet, cell, cell, s.valueText)
+		}
+	}
+	return sheet
+}
+
+// dumpCellValue はDBの値をセルに書き込む文字列に変換します。NULLは Load でNULLとして扱う <nil> です
+func dumpCellValue(v any) string {
+	if t, ok := v.(time.Time); ok {
+		return t.Format("2006-01-02 15:04:05")
+	}
+	return valueString(v)
+}
@@ -0,0 +1,431 @@
+package exceltesting
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/exp/slices"
+)
+
+// subsetChunkSize は外部キーで行を取得する際に、1つのSELECT文で指定する値の組の上限です
+const subsetChunkSize = 100
+
+// subsetRow は部分的に出力する行です
+type subsetRow struct {
+	record []any
+	// depth は起点から参照元の方向にたどった深さです。参照先としてのみ取得した行は -1 です
+	depth int
+	// count は出力する件数です。主キーがないテーブルで同じ値の行が複数ある場合に1より大きくなります
+	count int
+}
+
+// subsetTable は部分的に出力するテーブルの行です
+type subsetTable struct {
+	def  tableDef
+	keys []string
+	// keyIndexes は主キーのカラムの位置です。主キーがない場合は nil で、すべてのカラムの値で行を区別します
+	keyIndexes []int
+	rows       map[string]*subsetRow
+}
+
+// subsetBatch は関連する行をたどる対象の行です
+type subsetBatch struct {
+	table   string
+	records [][]any
+	depth   int
+}
+
+// subsetCollector は起点の行から外部キーをたどって行を集めます
+type subsetCollector struct {
+	e       *exceltesing
+	tx      *sql.Tx
+	dialect Dialect
+	defs    map[string]tableDef
+	fks     []foreignKey
+	depth   int
+	tables  map[string]*subsetTable
+	queue   []subsetBatch
+}
+
+// collectSubset は opt.Roots の行と、外部キーでたどった関連する行を取得します。
+// テーブルは参照先を先に、行は主キーの順に並べ、Loadでそのまま投入できるようにします。
+//
+// 同時に更新されていても互いに整合する行を取得するため、1つの読み取り専用の REPEATABLE READ のトランザクションで取得します。
+func (e *exceltesing) collectSubset(ctx context.Context, defs []tableDef, opt DumpOptions) ([]dumpTable, error) {
+	tx, err := e.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	c := &subsetCollector{
+		e:       e,
+		tx:      tx,
+		dialect: detectDialect(e.db),
+		defs:    make(map[string]tableDef, len(defs)),
+		depth:   opt.FKDepth,
+		tables:  make(map[string]*subsetTable),
+	}
+	for _, d := range defs {
+		c.defs[d.name] = d
+	}
+	if opt.FollowFKs {
+		fks, err := c.dialect.introspector().foreignKeys(ctx, tx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get foreign keys: %w", err)
+		}
+		for _, fk := range fks {
+			_, ok1 := c.defs[fk.table]
+			_, ok2 := c.defs[fk.refTable]
+			if ok1 && ok2 {
+				c.fks = append(c.fks, fk)
+			}
+		}
+	}
+
+	for _, root := range opt.Roots {
+		d, ok := c.defs[root.Table]
+		if !ok {
+			return nil, fmt.Errorf("root table %s not found", root.Table)
+		}
+		records, err := selectDumpRecords(ctx, tx, buildDumpQuery(d, root.Where, nil, 0), len(d.columns))
+		if err != nil {
+			return nil, fmt.Errorf("failed to select root records of %s: %w", root.Table, err)
+		}
+		if _, err := c.table(ctx, root.Table); err != nil {
+			return nil, err
+		}
+		c.queue = append(c.queue, subsetBatch{table: root.Table, records: records, depth: 0})
+	}
+
+	for len(c.queue) > 0 {
+		b := c.queue[0]
+		c.queue = c.queue[1:]
+		if len(b.records) == 0 {
+			continue
+		}
+		if err := c.visit(ctx, b); err != nil {
+			return nil, err
+		}
+	}
+	return c.result(), nil
+}
+
+// table は name の集めた行を返します
+func (c *subsetCollector) table(ctx context.Context, name string) (*subsetTable, error) {
+	if t, ok := c.tables[name]; ok {
+		return t, nil
+	}
+	keys, err := c.e.getPrimaryKeyColumns(ctx, c.tx, name)
+	if err != nil && !errors.Is(err, errPrimaryKeyNotFound) {
+		return nil, fmt.Errorf("failed to get primary key of %s: %w", name, err)
+	}
+	t := &subsetTable{def: c.defs[name], rows: make(map[string]*subsetRow)}
+	if keys != "" {
+		t.keys = strings.Split(keys, ",")
+		if indexes := columnIndexesOf(t.def, t.keys); len(indexes) == len(t.keys) {
+			t.keyIndexes = indexes
+		}
+	}
+	c.tables[name] = t
+	return t, nil
+}
+
+// visit は b の行を追加し、新たに取得した行の参照先と、深さの範囲内の参照元の行を取得します
+func (c *subsetCollector) visit(ctx context.Context, b subsetBatch) error {
+	t, err := c.table(ctx, b.table)
+	if err != nil {
+		return err
+	}
+
+	// 主キーがないテーブルは同じ値の行を区別できないが、同じ値の行は同じ条件で必ずまとめて取得されるため、
+	// 1回の取得での件数の最大を出力する件数とする
+	counts := make(map[string]int, len(b.records))
+	var added, expanded [][]any
+	for _, record := range b.records {
+		key := tupleKey(record, t.keyIndexes)
+		counts[key]++
+		r, ok := t.rows[key]
+		if !ok {
+			r = &subsetRow{record: record, depth: -1, count: 1}
+			t.rows[key] = r
+			added = append(added, record)
+		}
+		if t.keyIndexes == nil && counts[key] > r.count {
+			r.count = counts[key]
+		}
+		if b.depth >= 0 && (r.depth < 0 || b.depth < r.depth) {
+			r.depth = b.depth
+			if b.depth < c.depth {
+				expanded = append(expanded, record)
+			}
+		}
+	}
+
+	for _, fk := range c.fks {
+		if fk.table == b.table && len(added) > 0 {
+			records, err := c.selectByColumns(ctx, fk.refTable, fk.refColumns, columnValues(t.def, added, fk.columns))
+			if err != nil {
+				return fmt.Errorf("failed to select records of %s referenced by %s: %w", fk.refTable, fk.name, err)
+			}
+			c.queue = append(c.queue, subsetBatch{table: fk.refTable, records: records, depth: -1})
+		}
+		if fk.refTable == b.table && len(expanded) > 0 {
+			records, err := c.selectByColumns(ctx, fk.table, fk.columns, columnValues(t.def, expanded, fk.refColumns))
+			if err != nil {
+				return fmt.Errorf("failed to select records of %s referencing by %s: %w", fk.table, fk.name, err)
+			}
+			c.queue = append(c.queue, subsetBatch{table: fk.table, records: records, depth: b.depth + 1})
+		}
+	}
+	return nil
+}
+
+// selectByColumns はテーブル name のカラム columns の値が tuples のいずれかに一致する行を取得します。
+// NULLを含む値の組は参照していないため除きます。
+func (c *subsetCollector) selectByColumns(ctx context.Context, name string, columns []string, tuples [][]any) ([][]any, error) {
+	d := c.defs[name]
+	seen := make(map[string]bool, len(tuples))
+	filtered := tuples[:0]
+	for _, tuple := range tuples {
+		key := tupleKey(tuple, nil)
+		if seen[key] || slices.IndexFunc(tuple, func(v any) bool { return v == nil }) >= 0 {
+			continue
+		}
+		seen[key] = true
+		filtered = append(filtered, tuple)
+	}
+
+	var records [][]any
+	for len(filtered) > 0 {
+		n := subsetChunkSize
+		if len(filtered) < n {
+			n = len(filtered)
+		}
+		conds := make([]string, 0, n)
+		args := make([]any, 0, n*len(columns))
+		for _, tuple := range filtered[:n] {
+			eqs := make([]string, 0, len(columns))
+			for i, col := range columns {
+				args = append(args, tuple[i])
+				eqs = append(eqs, fmt.Sprintf("%s = %s", col, c.dialect.placeholder(len(args))))
+			}
+			conds = append(conds, "("+strings.Join(eqs, " AND ")+")")
+		}
+		rs, err := selectDumpRecords(ctx, c.tx, buildDumpQuery(d, strings.Join(conds, " OR "), nil, 0), len(d.columns), args...)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, rs...)
+		filtered = filtered[n:]
+	}
+	return records, nil
+}
+
+// result は集めた行をテーブルの依存順、行の主キー順に並べて返します
+func (c *subsetCollector) result() []dumpTable {
+	names := make([]string, 0, len(c.tables))
+	for name := range c.tables {
+		names = append(names, name)
+	}
+
+	tables := make([]dumpTable, 0, len(names))
+	for _, name := range sortTablesByDependency(names, c.fks) {
+		t := c.tables[name]
+		records := make([][]any, 0, len(t.rows))
+		for _, r := range t.rows {
+			for i := 0; i < r.count; i++ {
+				records = append(records, r.record)
+			}
+		}
+		sortRecords(records, columnIndexesOf(t.def, t.keys))
+		for _, fk := range c.fks {
+			if fk.table == name && fk.refTable == name {
+				records = orderSelfReferences(records, columnIndexesOf(t.def, fk.columns), columnIndexesOf(t.def, fk.refColumns))
+			}
+		}
+		tables = append(tables, dumpTable{def: t.def, records: records})
+	}
+	return tables
+}
+
+// sortTablesByDependency はテーブルを外部キーの参照先が先になるよう並べます。
+// 順序が決まらないテーブルや循環参照しているテーブルはテーブル名の順に並べます。
+func sortTablesByDependency(names []string, fks []foreignKey) []string {
+	remaining := append([]string(nil), names...)
+	sort.Strings(remaining)
+
+	deps := make(map[string][]string)
+	for _, fk := range fks {
+		if fk.table != fk.refTable && slices.Contains(names, fk.table) && slices.Contains(names, fk.refTable) {
+			deps[fk.table] = append(deps[fk.table], fk.refTable)
+		}
+	}
+
+	sorted := make([]string, 0, len(names))
+	for len(remaining) > 0 {
+		i := slices.IndexFunc(remaining, func(name string) bool {
+			for _, dep := range deps[name] {
+				if !slices.Contains(sorted, dep) {
+					return false
+				}
+			}
+			return true
+		})
+		if i < 0 {
+			i = 0
+		}
+		sorted = append(sorted, remaining[i])
+		remaining = slices.Delete(remaining, i, i+1)
+	}
+	return sorted
+}
+
+// sortRecords は行を keys のカラムの順に並べます。keys がない場合はすべてのカラムの順です
+func sortRecords(records [][]any, keys []int) {
+	sort.SliceStable(records, func(i, j int) bool {
+		a, b := records[i], records[j]
+		if len(keys) == 0 {
+			for k := range a {
+				if c := compareValues(a[k], b[k]); c != 0 {
+					return c < 0
+				}
+			}
+			return false
+		}
+		for _, k := range keys {
+			if c := compareValues(a[k], b[k]); c != 0 {
+				return c < 0
+			}
+		}
+		return false
+	})
+}
+
+// orderSelfReferences は自身を参照するテーブルの行を、参照先の行が先になるよう並べ替えます。
+// columns は参照元のカラム、refColumns は参照先のカラムの位置です。
+func orderSelfReferences(records [][]any, columns, refColumns []int) [][]any {
+	index := make(map[string]int, len(records))
+	for i, record := range records {
+		index[tupleKey(record, refColumns)] = i
+	}
+
+	sorted := make([][]any, 0, len(records))
+	visited := make([]bool, len(records))
+	var visit func(i int)
+	visit = func(i int) {
+		if visited[i] {
+			return
+		}
+		visited[i] = true
+		if j, ok := index[tupleKey(records[i], columns)]; ok {
+			visit(j)
+		}
+		sorted = append(sorted, records[i])
+	}
+	for i := range records {
+		visit(i)
+	}
+	return sorted
+}
+
+// compareValues はデータベースから取得した値を比較します。NULLは最初に並べます
+func compareValues(a, b any) int {
+	if a == nil || b == nil {
+		switch {
+		case a == nil && b == nil:
+			return 0
+		case a == nil:
+			return -1
+		default:
+			return 1
+		}
+	}
+	switch av := a.(type) {
+	case int64:
+		if bv, ok := b.(int64); ok {
+			return compareOrdered(av, bv)
+		}
+	case float64:
+		if bv, ok := b.(float64); ok {
+			return compareOrdered(av, bv)
+		}
+	case string:
+		if bv, ok := b.(string); ok {
+			return strings.Compare(av, bv)
+		}
+	case []byte:
+		if bv, ok := b.([]byte); ok {
+			return bytes.Compare(av, bv)
+		}
+	case time.Time:
+		if bv, ok := b.(time.Time); ok {
+			switch {
+			case av.Before(bv):
+				return -1
+			case av.After(bv):
+				return 1
+			}
+			return 0
+		}
+	}
+	return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+}
+
+func compareOrdered[T int64 | float64](a, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// tupleKey は record の indexes のカラムの値を一意なキーにします。indexes が nil の場合はすべてのカラムです。
+// NULLと文字列の "<nil>"、秒未満のみが異なる日時などを区別するため、出力する文字列ではなく型と値からキーを作成します。
+func tupleKey(record []any, indexes []int) string {
+	if indexes == nil {
+		indexes = make([]int, len(record))
+		for i := range record {
+			indexes[i] = i
+		}
+	}
+	vs := make([]string, 0, len(indexes))
+	for _, i := range indexes {
+		vs = append(vs, fmt.Sprintf("%T %#v", record[i], record[i]))
+	}
+	return strings.Join(vs, "\x00")
+}
+
+// columnValues は records から columns のカラムの値の組を取り出します
+func columnValues(d tableDef, records [][]any, columns []string) [][]any {
+	indexes := columnIndexesOf(d, columns)
+	tuples := make([][]any, 0, len(records))
+	for _, record := range records {
+		tuple := make([]any, 0, len(indexes))
+		for _, i := range indexes {
+			tuple = append(tuple, record[i])
+		}
+		tuples = append(tuples, tuple)
+	}
+	return tuples
+}
+
+// columnIndexesOf はテーブル d における columns のカラムの位置を返します
+func columnIndexesOf(d tableDef, columns []string) []int {
+	indexes := make([]int, 0, len(columns))
+	for _, col := range columns {
+		i := slices.IndexFunc(d.columns, func(c columnDef) bool { return c.name == col })
+		if i >= 0 {
+			indexes = append(indexes, i)
+		}
+	}
+	return indexes
+}
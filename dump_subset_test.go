@@ -0,0 +1,265 @@
+package exceltesting
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fc-shota-miyazaki/go-exceltesting/testonly"
+	"github.com/google/go-cmp/cmp"
+	"github.com/xuri/excelize/v2"
+)
+
+func TestDump_Roots(t *testing.T) {
+	conn := testonly.OpenTestDB(t)
+	defer conn.Close()
+
+	if _, err := conn.Exec(`DROP TABLE IF EXISTS subset_order_line, subset_order, subset_customer, subset_product;
+CREATE TABLE subset_customer(id int PRIMARY KEY, name text NOT NULL, referrer_id int REFERENCES subset_customer(id));
+CREATE TABLE subset_product(id int PRIMARY KEY, name text NOT NULL);
+CREATE TABLE subset_order(id int PRIMARY KEY, customer_id int NOT NULL REFERENCES subset_customer(id));
+CREATE TABLE subset_order_line(order_id int REFERENCES subset_order(id), line_no int, product_id int NOT NULL REFERENCES subset_product(id), PRIMARY KEY (order_id, line_no));`); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		conn.Exec(`DROP TABLE IF EXISTS subset_order_line, subset_order, subset_customer, subset_product;`)
+	})
+	seed := `TRUNCATE subset_order_line, subset_order, subset_customer, subset_product;
+INSERT INTO subset_customer VALUES (1,'Alice',NULL),(3,'Carol',NULL),(2,'Bob',3),(4,'Dave',NULL);
+INSERT INTO subset_product VALUES (10,'Pen'),(11,'Note'),(12,'Ink');
+INSERT INTO subset_order VALUES (100,2),(101,2),(102,4);
+INSERT INTO subset_order_line VALUES (100,1,10),(100,2,11),(102,1,12);`
+
+	tests := []struct {
+		name  string
+		depth int
+		want  map[string][][]string
+	}{
+		{
+			name:  "referenced rows only",
+			depth: 0,
+			want: map[string][][]string{
+				"subset_customer": {{"3", "Carol", "<nil>"}, {"2", "Bob", "3"}},
+			},
+		},
+		{
+			name:  "referencing rows up to depth",
+			depth: 1,
+			want: map[string][][]string{
+				"subset_customer": {{"3", "Carol", "<nil>"}, {"2", "Bob", "3"}},
+				"subset_order":    {{"100", "2"}, {"101", "2"}},
+			},
+		},
+		{
+			name:  "referenced rows of referencing rows",
+			depth: 3,
+			want: map[string][][]string{
+				"subset_customer":   {{"3", "Carol", "<nil>"}, {"2", "Bob", "3"}},
+				"subset_order":      {{"100", "2"}, {"101", "2"}},
+				"subset_order_line": {{"100", "1", "10"}, {"100", "2", "11"}},
+				"subset_product":    {{"10", "Pen"}, {"11", "Note"}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := conn.Exec(seed); err != nil {
+				t.Fatal(err)
+			}
+			opt := DumpOptions{
+				TargetBookPath: filepath.Join(t.TempDir(), "dump.xlsx"),
+				Roots:          []DumpRoot{{Table: "subset_customer", Where: "id = 2"}},
+				FollowFKs:      true,
+				FKDepth:        tt.depth,
+			}
+			if err := Dump(context.Background(), conn, opt); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.want, readDumpBook(t, opt.TargetBookPath)); diff != "" {
+				t.Errorf("Dump() mismatch (-want +got):\n%s", diff)
+			}
+
+			// 他の行が残ったデータベースに参照するテーブルをまとめて空にして投入でき、再度出力すると同じ行になる
+			r := LoadRequest{TargetBookPath: opt.TargetBookPath, TruncateReferencingTables: true}
+			if err := New(conn).LoadWithContext(context.Background(), r); err != nil {
+				t.Fatalf("LoadWithContext() error = %v", err)
+			}
+			path := opt.TargetBookPath
+			opt.TargetBookPath = filepath.Join(t.TempDir(), "reload.xlsx")
+			if err := Dump(context.Background(), conn, opt); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(readDumpBook(t, path), readDumpBook(t, opt.TargetBookPath)); diff != "" {
+				t.Errorf("Dump() after Load mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// readDumpBook はBookのシートをテーブル名ごとの行として読み込みます
+func readDumpBook(t *testing.T, path string) map[string][][]string {
+	t.Helper()
+
+	f, err := excelize.OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	e := &exceltesing{}
+	got := make(map[string][][]string)
+	for _, sheet := range f.GetSheetList() {
+		s, err := e.loadExcelSheet(f, sheet)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got[s.name] = s.data
+	}
+	return got
+}
+
+func Test_sortTablesByDependency(t *testing.T) {
+	fks := []foreignKey{
+		{table: "order_line", refTable: "orders"},
+		{table: "order_line", refTable: "product"},
+		{table: "orders", refTable: "customer"},
+		{table: "customer", refTable: "customer"},
+		{table: "a", refTable: "b"},
+		{table: "b", refTable: "a"},
+	}
+	tests := []struct {
+		name  string
+		names []string
+		want  []string
+	}{
+		{
+			name:  "referenced tables first",
+			names: []string{"order_line", "product", "orders", "customer"},
+			want:  []string{"customer", "orders", "product", "order_line"},
+		},
+		{
+			name:  "cycle is ordered by name after other tables",
+			names: []string{"b", "a", "customer"},
+			want:  []string{"customer", "a", "b"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := sortTablesByDependency(tt.names, fks)
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("sortTablesByDependency() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_subsetCollector_visit(t *testing.T) {
+	at := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		keys    []string
+		batches [][][]any
+		want    [][]any
+	}{
+		{
+			name: "rows with the same primary key",
+			keys: []string{"id"},
+			batches: [][][]any{
+				{{int64(1), "a"}, {int64(2), "b"}},
+				{{int64(1), "a"}},
+			},
+			want: [][]any{{int64(1), "a"}, {int64(2), "b"}},
+		},
+		{
+			name: "identical rows without primary key",
+			batches: [][][]any{
+				{{int64(1), "a"}, {int64(1), "a"}},
+				{{int64(1), "a"}, {int64(1), "a"}},
+				{{int64(1), "a"}},
+			},
+			want: [][]any{{int64(1), "a"}, {int64(1), "a"}},
+		},
+		{
+			name: "null and string of nil",
+			batches: [][][]any{
+				{{int64(1), nil}, {int64(1), "<nil>"}},
+			},
+			want: [][]any{{int64(1), nil}, {int64(1), "<nil>"}},
+		},
+		{
+			name: "times differing below a second",
+			batches: [][][]any{
+				{{int64(1), at}, {int64(1), at.Add(time.Millisecond)}},
+			},
+			want: [][]any{{int64(1), at}, {int64(1), at.Add(time.Millisecond)}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			def := tableDef{name: "log", columns: []columnDef{{name: "id"}, {name: "v"}}}
+			st := &subsetTable{def: def, keys: tt.keys, rows: make(map[string]*subsetRow)}
+			if tt.keys != nil {
+				st.keyIndexes = columnIndexesOf(def, tt.keys)
+			}
+			c := &subsetCollector{
+				defs:   map[string]tableDef{"log": def},
+				tables: map[string]*subsetTable{"log": st},
+			}
+			for _, records := range tt.batches {
+				if err := c.visit(context.Background(), subsetBatch{table: "log", records: records, depth: -1}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got := c.result()
+			if diff := cmp.Diff(tt.want, got[0].records); diff != "" {
+				t.Errorf("result() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_orderSelfReferences(t *testing.T) {
+	// id, parent_id
+	records := [][]any{
+		{int64(1), int64(3)},
+		{int64(2), nil},
+		{int64(3), int64(2)},
+		{int64(4), int64(9)},
+	}
+	want := [][]any{
+		{int64(2), nil},
+		{int64(3), int64(2)},
+		{int64(1), int64(3)},
+		{int64(4), int64(9)},
+	}
+	got := orderSelfReferences(records, []int{1}, []int{0})
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("orderSelfReferences() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func Test_compareValues(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name string
+		a, b any
+		want int
+	}{
+		{name: "nil first", a: nil, b: int64(1), want: -1},
+		{name: "both nil", a: nil, b: nil, want: 0},
+		{name: "int", a: int64(10), b: int64(9), want: 1},
+		{name: "float", a: float64(1.5), b: float64(2), want: -1},
+		{name: "string", a: "00002", b: "00010", want: -1},
+		{name: "bytes", a: []byte("b"), b: []byte("a"), want: 1},
+		{name: "time", a: now, b: now.Add(time.Second), want: -1},
+		{name: "different types", a: int64(1), b: "1", want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := compareValues(tt.a, tt.b); got != tt.want {
+				t.Errorf("compareValues() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,251 @@
+package exceltesting
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fc-shota-miyazaki/go-exceltesting/testonly"
+	"github.com/google/go-cmp/cmp"
+	"github.com/xuri/excelize/v2"
+)
+
+func TestDump(t *testing.T) {
+	conn := testonly.OpenTestDB(t)
+	defer conn.Close()
+
+	testonly.ExecSQLFile(t, conn, filepath.Join("testdata", "schema", "ddl.sql"))
+	if _, err := conn.Exec(`TRUNCATE company;
+INSERT INTO company (company_cd,company_name,founded_year,created_at,updated_at,revision) VALUES ('00001','Future',1989,'2022-01-02 03:04:05','2022-01-02 03:04:05',1);`); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "dump.xlsx")
+	if err := Dump(context.Background(), conn, DumpOptions{
+		TargetBookPath: path,
+		Tables:         []string{"company", "test_x"},
+		Limit:          10,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := excelize.OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if diff := cmp.Diff([]string{"company", "test_x"}, f.GetSheetList()); diff != "" {
+		t.Errorf("sheets mismatch (-want +got):\n%s", diff)
+	}
+
+	e := &exceltesing{}
+	got, err := e.loadExcelSheet(f, "company")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"company_cd", "company_name", "founded_year", "created_at", "updated_at", "revision"}, got.columns); diff != "" {
+		t.Errorf("columns mismatch (-want +got):\n%s", diff)
+	}
+	if len(got.data) != 1 || got.data[0][0] != "00001" {
+		t.Errorf("data = %v, want 1 row of company 00001", got.data)
+	}
+
+	if err := Dump(context.Background(), conn, DumpOptions{
+		TargetBookPath: path,
+		Tables:         []string{"not_exists"},
+	}); err == nil {
+		t.Error("Dump() should return error for table which does not exist")
+	}
+}
+
+func TestDump_WhereAndQuery(t *testing.T) {
+	conn := testonly.OpenTestDB(t)
+	defer conn.Close()
+
+	testonly.ExecSQLFile(t, conn, filepath.Join("testdata", "schema", "ddl.sql"))
+	if _, err := conn.Exec(`TRUNCATE company;
+INSERT INTO company (company_cd,company_name,founded_year,created_at,updated_at,revision) VALUES ('00003','FutureOne',2002,current_timestamp,current_timestamp,1),('00001','Future',1989,current_timestamp,current_timestamp,1),('00002','YDC',1972,current_timestamp,current_timestamp,1);`); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "dump.xlsx")
+	if err := Dump(context.Background(), conn, DumpOptions{
+		TargetBookPath: path,
+		Tables:         []string{"company"},
+		Where:          map[string]string{"company": "founded_year < 2000"},
+		Queries:        []DumpQuery{{Name: "会社数", Query: "SELECT count(*) AS cnt FROM company;"}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := excelize.OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	e := &exceltesing{}
+	company, err := e.loadExcelSheet(f, "company")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 主キーの順に出力する
+	var cds []string
+	for _, row := range company.data {
+		cds = append(cds, row[0])
+	}
+	if diff := cmp.Diff([]string{"00001", "00002"}, cds); diff != "" {
+		t.Errorf("company_cd mismatch (-want +got):\n%s", diff)
+	}
+
+	// クエリの結果はクエリシートとして読み込める
+	q, err := e.loadExcelSheet(f, "会社数")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.query != "SELECT count(*) AS cnt FROM company" {
+		t.Errorf("query = %s", q.query)
+	}
+	if diff := cmp.Diff([][]string{{"3"}}, q.data); diff != "" {
+		t.Errorf("query data mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func Test_buildDumpQuery(t *testing.T) {
+	d := tableDef{name: "orders", columns: []columnDef{{name: "id"}, {name: "created_at"}}}
+	tests := []struct {
+		name    string
+		where   string
+		orderBy []string
+		limit   int
+		want    string
+	}{
+		{
+			name: "no options",
+			want: "SELECT id, created_at FROM orders",
+		},
+		{
+			name:    "where, order by and limit",
+			where:   " created_at > now() - interval '1 day' ",
+			orderBy: []string{"created_at", "id"},
+			limit:   100,
+			want:    "SELECT id, created_at FROM orders WHERE created_at > now() - interval '1 day' ORDER BY created_at, id LIMIT 100",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := buildDumpQuery(d, tt.where, tt.orderBy, tt.limit); got != tt.want {
+				t.Errorf("buildDumpQuery() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_validateDumpOptions(t *testing.T) {
+	defs := []tableDef{{name: "orders"}}
+	tests := []struct {
+		name    string
+		opt     DumpOptions
+		wantErr bool
+	}{
+		{
+			name: "valid",
+			opt: DumpOptions{
+				Where:   map[string]string{"orders": "id > 0"},
+				OrderBy: map[string][]string{"orders": {"id"}},
+				Queries: []DumpQuery{{Name: "q", Query: "WITH x AS (SELECT 1) SELECT * FROM x"}},
+			},
+		},
+		{
+			name:    "where for table not dumped",
+			opt:     DumpOptions{Where: map[string]string{"customers": "id > 0"}},
+			wantErr: true,
+		},
+		{
+			name:    "order by for table not dumped",
+			opt:     DumpOptions{OrderBy: map[string][]string{"customers": {"id"}}},
+			wantErr: true,
+		},
+		{
+			name:    "not select statement",
+			opt:     DumpOptions{Queries: []DumpQuery{{Name: "q", Query: "DELETE FROM orders"}}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateDumpOptions(defs, tt.opt); (err != nil) != tt.wantErr {
+				t.Errorf("validateDumpOptions() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_writeDumpSheet(t *testing.T) {
+	f := excelize.NewFile()
+	defer f.Close()
+
+	d := tableDef{
+		name:    "company",
+		comment: "会社",
+		columns: []columnDef{
+			{name: "company_cd", comment: "会社コード"},
+			{name: "company_name", comment: "会社名"},
+			{name: "revision"},
+		},
+	}
+	records := [][]any{
+		{"00001", []byte("Future"), int64(1)},
+		{"00002", nil, int64(2)},
+	}
+	sheet := writeDumpSheet(f, newDumpStyles(f), d, records, []string{"revision"})
+	if sheet != "会社" {
+		t.Errorf("writeDumpSheet() = %s, want 会社", sheet)
+	}
+
+	e := &exceltesing{}
+	got, err := e.loadExcelSheet(f, sheet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{{"00001", "Future", "1"}, {"00002", "<nil>", "2"}}
+	if diff := cmp.Diff(want, got.data); diff != "" {
+		t.Errorf("data mismatch (-want +got):\n%s", diff)
+	}
+	if got.name != "company" {
+		t.Errorf("table name = %s, want company", got.name)
+	}
+
+	comment, _ := f.GetCellValue(sheet, "B5")
+	if comment != "会社コード" {
+		t.Errorf("B5 = %s, want 会社コード", comment)
+	}
+	normal, _ := f.GetCellStyle(sheet, "B6")
+	system, _ := f.GetCellStyle(sheet, "D6")
+	if normal == system {
+		t.Error("system column should have different style")
+	}
+}
+
+func Test_dumpCellValue(t *testing.T) {
+	tests := []struct {
+		name string
+		v    any
+		want string
+	}{
+		{name: "nil", v: nil, want: "<nil>"},
+		{name: "bytes", v: []byte("abc"), want: "abc"},
+		{name: "time", v: time.Date(2022, 1, 2, 3, 4, 5, 6, time.UTC), want: "2022-01-02 03:04:05"},
+		{name: "float", v: float64(1000000), want: "1000000"},
+		{name: "int", v: int64(42), want: "42"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := dumpCellValue(tt.v); got != tt.want {
+				t.Errorf("dumpCellValue() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
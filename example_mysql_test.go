@@ -1,8 +1,10 @@
 package exceltesting_test
 
 import (
+	"context"
 	"database/sql"
 	"encoding/csv"
+	"errors"
 	"fmt"
 	"net/url"
 	"os"
@@ -95,6 +97,25 @@ func TestExample_LoadMySQL(t *testing.T) {
 	})
 }
 
+func TestExample_LoadMySQL_CellError(t *testing.T) {
+	conn := openMySQLTestingConn(t)
+
+	e := exceltesting.New(conn)
+	err := e.LoadWithContext(context.Background(), exceltesting.LoadRequest{
+		TargetBookPath: filepath.Join("testdata", "load_error.xlsx"),
+		// 不正な値を警告ではなくエラーにする
+		SessionSettings: map[string]string{"sql_mode": "STRICT_TRANS_TABLES"},
+	})
+
+	var got *exceltesting.CellError
+	if !errors.As(err, &got) {
+		t.Fatalf("LoadWithContext() should return *CellError but %v", err)
+	}
+	if got.Sheet != "会社" || got.Row != 2 || got.Column != "founded_year" || got.Value != "abc" {
+		t.Errorf("LoadWithContext() CellError = %v", got)
+	}
+}
+
 func TestExample_LoadRawFromCSVMySQL(t *testing.T) {
 	conn := openMySQLTestingConn(t)
 	if _, err := conn.Exec("TRUNCATE TABLE company;"); err != nil {
@@ -130,3 +151,67 @@ func TestExample_LoadRawFromCSVMySQL(t *testing.T) {
 
 	tx.Rollback()
 }
+
+func TestExample_LoadMySQL_RestoreSessionSettings(t *testing.T) {
+	conn := openMySQLTestingConn(t)
+	// Load とその後のクエリを同じ接続で実行する
+	conn.SetMaxOpenConns(1)
+	t.Cleanup(func() { conn.SetMaxOpenConns(0) })
+
+	var before string
+	if err := conn.QueryRow("SELECT @@SESSION.time_zone").Scan(&before); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.Exec("TRUNCATE TABLE company;"); err != nil {
+		t.Fatal(err)
+	}
+
+	exceltesting.New(conn).Load(t, exceltesting.LoadRequest{
+		TargetBookPath:  filepath.Join("testdata", "load_example.xlsx"),
+		SessionSettings: map[string]string{"time_zone": "+09:00"},
+	})
+
+	var after string
+	if err := conn.QueryRow("SELECT @@SESSION.time_zone").Scan(&after); err != nil {
+		t.Fatal(err)
+	}
+	if after != before {
+		t.Errorf("time_zone of pooled connection = %s, want %s", after, before)
+	}
+}
+
+func TestExample_DiffDBMySQL_RestoreDatabase(t *testing.T) {
+	conn := openMySQLTestingConn(t)
+	// DiffDB は比較元と比較先で2つの接続を利用するため、その2つの接続を確認する
+	conn.SetMaxOpenConns(2)
+	t.Cleanup(func() { conn.SetMaxOpenConns(0) })
+
+	var want string
+	if err := conn.QueryRow("SELECT DATABASE()").Scan(&want); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	if _, err := exceltesting.New(conn).DiffDB(ctx, conn, exceltesting.DiffDBRequest{
+		SourceSchema: "information_schema",
+		TargetSchema: "information_schema",
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		c, err := conn.Conn(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close()
+
+		var got string
+		if err := c.QueryRowContext(ctx, "SELECT DATABASE()").Scan(&got); err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("database of pooled connection = %s, want %s", got, want)
+		}
+	}
+}
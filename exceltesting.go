@@ -4,23 +4,32 @@ import (
 	"context"
 	"database/sql"
 	"encoding/csv"
+	"errors"
 	"fmt"
-	"math/big"
+	"io"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
-	"testing"
+	"time"
 
-	"github.com/google/go-cmp/cmp"
-	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/xuri/excelize/v2"
 	"golang.org/x/exp/slices"
 )
 
 const (
 	tempTablePrefix = "temp_"
+
+	savepointInsert  = "exceltesting_insert"
+	savepointRow     = "exceltesting_row"
+	savepointCompare = "exceltesting_compare"
+	// savepointCompareTx は CompareTx で呼び出し元のトランザクションに影響を与えないためのセーブポイントです
+	savepointCompareTx = "exceltesting_compare_tx"
 )
 
+// errPrimaryKeyNotFound はテーブルに主キーも一意インデックスもないことを表します
+var errPrimaryKeyNotFound = errors.New("primary key not found")
+
 // New はExcelからテストデータを投入できる構造体のファクトリ関数です
 func New(db *sql.DB) *exceltesing {
 	if db == nil {
@@ -33,8 +42,25 @@ type exceltesing struct {
 	db *sql.DB
 }
 
+// queryer は *sql.DB と *sql.Tx に共通するクエリ実行のインターフェースです
+type queryer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// TestingT は Load や Compare などのテストヘルパーが利用する testing.TB のサブセットです。
+// *testing.T 、*testing.B 、*testing.F などの testing.TB のほか、
+// Ginkgo の GinkgoT() など同じメソッドを持つテストフレームワークの値を渡せます。
+type TestingT interface {
+	Helper()
+	Logf(format string, args ...any)
+	Errorf(format string, args ...any)
+	Fatalf(format string, args ...any)
+}
+
 // Load はExcelのBookを読み込み、データベースに事前データを投入します。
-func (e *exceltesing) Load(t *testing.T, r LoadRequest) {
+func (e *exceltesing) Load(t TestingT, r LoadRequest) {
 	t.Helper()
 	ctx := context.Background()
 
@@ -44,45 +70,82 @@ func (e *exceltesing) Load(t *testing.T, r LoadRequest) {
 }
 
 func (e *exceltesing) LoadWithContext(ctx context.Context, r LoadRequest) error {
+	ctx, cancel := withTimeout(ctx, r.Timeout)
+	defer cancel()
+
+	if r.DryRun != nil {
+		return e.dryRun(ctx, r)
+	}
+
 	tx, err := e.db.BeginTx(ctx, nil)
 	if err != nil {
 		return fmt.Errorf("exceltesing: start transaction: %w", err)
 	}
 	defer tx.Rollback()
 
+	restore, err := applySessionSettings(ctx, tx, detectDialect(e.db), r.SessionSettings)
+	defer restore()
+	if err != nil {
+		return fmt.Errorf("exceltesing: apply session settings: %w", err)
+	}
+
 	f, err := excelize.OpenFile(r.TargetBookPath)
 	if err != nil {
 		return fmt.Errorf("exceltesing: excelize.OpenFile: %w", err)
 	}
 	defer f.Close()
 
+	// 外部キーで関連するテーブルは1つずつ空にできないため、指定された場合は先にまとめて空にする
+	var related []string
+	if r.TruncateReferencingTables {
+		if related, err = e.relatedTables(ctx, tx, f, r); err != nil {
+			return fmt.Errorf("exceltesing: %w", err)
+		}
+		if err := truncateTables(ctx, tx, detectDialect(e.db), related); err != nil {
+			return fmt.Errorf("exceltesing: truncate related tables: %w", err)
+		}
+	}
+
 	for _, sheet := range f.GetSheetList() {
-		if slices.Contains(r.IgnoreSheet, sheet) {
+		// クエリシートは比較専用のため投入しない
+		if !isTargetSheet(sheet, r.SheetPrefix, r.IgnoreSheet) || isQuerySheet(f, sheet) {
 			continue
 		}
-		if strings.HasPrefix(sheet, r.SheetPrefix) {
-			table, err := e.loadExcelSheet(f, sheet)
+		if isSQLSheet(f, sheet, r.SQLSheetPrefix) {
+			stmts, err := loadSQLSheet(f, sheet)
 			if err != nil {
-				return fmt.Errorf("exceltesing: load excel sheet, sheet = %s: %w", sheet, err)
+				return fmt.Errorf("exceltesing: load sql sheet, sheet = %s: %w", sheet, err)
 			}
-
-			if r.EnableAutoCompleteNotNullColumn {
-				cs, err := e.tableColumns(table.name)
-				if err != nil {
-					return fmt.Errorf("exceltesing: get table(%s)'s columns: %w", table.name, err)
-				}
-				for i := range cs {
-					cs[i].data = defaultValueFromDBType(cs[i].dataType)
-				}
-				table.merge(cs)
+			if err := execStatements(ctx, tx, stmts); err != nil {
+				return fmt.Errorf("exceltesing: exec sql sheet, sheet = %s: %w", sheet, err)
 			}
+			continue
+		}
 
-			if err := e.insertData(table); err != nil {
-				return fmt.Errorf("exceltesing: insert data to %s: %w", table.name, err)
-			}
+		table, err := e.loadTable(ctx, tx, f, sheet, r)
+		if err != nil {
+			return err
+		}
+
+		if err := execStatements(ctx, tx, table.before); err != nil {
+			return fmt.Errorf("exceltesing: exec before hook, sheet = %s: %w", sheet, err)
+		}
+		// まとめて空にしたテーブルは、同じテーブルの最初のシートでは空にしない
+		i := slices.Index(related, table.name)
+		if i >= 0 {
+			related = slices.Delete(related, i, i+1)
+		}
+		if err := e.insertDataTx(ctx, tx, table, i < 0); err != nil {
+			return fmt.Errorf("exceltesing: insert data to %s: %w", table.name, err)
+		}
+		if err := execStatements(ctx, tx, table.after); err != nil {
+			return fmt.Errorf("exceltesing: exec after hook, sheet = %s: %w", sheet, err)
 		}
 	}
 
+	if err := restore(); err != nil {
+		return fmt.Errorf("exceltesing: restore session settings: %w", err)
+	}
 	if err := tx.Commit(); err != nil {
 		return fmt.Errorf("exceltesing: commit: %w", err)
 	}
@@ -96,76 +159,341 @@ func (e *exceltesing) LoadWithContext(ctx context.Context, r LoadRequest) error
 	return nil
 }
 
+// DryRun はデータベースに接続せずに、Loadで実行されるSQLスクリプトを LoadRequest.DryRun に書き込みます。
+//
+// SQLの方言は LoadRequest.Dialect で指定します。未指定の場合はPostgreSQLとみなします。
+// データベースのカラム定義が必要な EnableAutoCompleteNotNullColumn は利用できません。
+func DryRun(r LoadRequest) error {
+	if r.DryRun == nil {
+		return errors.New("exceltesing: LoadRequest.DryRun is nil")
+	}
+	if r.EnableAutoCompleteNotNullColumn {
+		return errors.New("exceltesing: EnableAutoCompleteNotNullColumn requires database connection")
+	}
+	e := &exceltesing{}
+	return e.dryRun(context.Background(), r)
+}
+
+// dryRun はLoadで実行されるSQLスクリプトを LoadRequest.DryRun に書き込みます
+func (e *exceltesing) dryRun(ctx context.Context, r LoadRequest) error {
+	d := r.Dialect
+	if d == "" {
+		d = DialectPostgres
+		if e.db != nil {
+			d = detectDialect(e.db)
+		}
+	}
+
+	f, err := excelize.OpenFile(r.TargetBookPath)
+	if err != nil {
+		return fmt.Errorf("exceltesing: excelize.OpenFile: %w", err)
+	}
+	defer f.Close()
+
+	var b strings.Builder
+	b.WriteString(d.beginStatement() + "\n")
+	if err := writeSessionSettings(&b, d, r.SessionSettings); err != nil {
+		return fmt.Errorf("exceltesing: write session settings: %w", err)
+	}
+	// 外部キーの取得にはデータベースへの接続が必要なため、接続がない場合は1つずつ空にする
+	var related []string
+	if r.TruncateReferencingTables && e.db != nil {
+		if related, err = e.relatedTables(ctx, e.db, f, r); err != nil {
+			return fmt.Errorf("exceltesing: %w", err)
+		}
+		writeStatements(&b, truncateStatements(d, related))
+	}
+	for _, sheet := range f.GetSheetList() {
+		if !isTargetSheet(sheet, r.SheetPrefix, r.IgnoreSheet) || isQuerySheet(f, sheet) {
+			continue
+		}
+		b.WriteString("\n-- sheet: " + sheet + "\n")
+
+		if isSQLSheet(f, sheet, r.SQLSheetPrefix) {
+			stmts, err := loadSQLSheet(f, sheet)
+			if err != nil {
+				return fmt.Errorf("exceltesing: load sql sheet, sheet = %s: %w", sheet, err)
+			}
+			writeStatements(&b, stmts)
+			continue
+		}
+
+		table, err := e.loadTable(ctx, e.db, f, sheet, r)
+		if err != nil {
+			return err
+		}
+
+		writeStatements(&b, table.before)
+		if i := slices.Index(related, table.name); i >= 0 {
+			related = slices.Delete(related, i, i+1)
+		} else {
+			b.WriteString(fmt.Sprintf("TRUNCATE TABLE %s;\n", table.name))
+		}
+		if len(table.data) > 0 {
+			b.WriteString(table.buildInsertSQL())
+		}
+		writeStatements(&b, table.after)
+	}
+	b.WriteString("\nCOMMIT;\n")
+
+	if _, err := io.WriteString(r.DryRun, b.String()); err != nil {
+		return fmt.Errorf("exceltesing: write sql script: %w", err)
+	}
+	return nil
+}
+
+// loadTable はシートを読み込み、必要に応じてNOT NULLカラムをデフォルト値で補完します
+func (e *exceltesing) loadTable(ctx context.Context, q queryer, f *excelize.File, sheet string, r LoadRequest) (*table, error) {
+	table, err := e.loadExcelSheet(f, sheet)
+	if err != nil {
+		return nil, fmt.Errorf("exceltesing: load excel sheet, sheet = %s: %w", sheet, err)
+	}
+
+	if r.EnableAutoCompleteNotNullColumn {
+		cs, err := e.tableColumns(ctx, q, table.name)
+		if err != nil {
+			return nil, fmt.Errorf("exceltesing: get table(%s)'s columns: %w", table.name, err)
+		}
+		for i := range cs {
+			cs[i].data = defaultValueFromDBType(cs[i].dataType)
+		}
+		table.merge(cs)
+	}
+	return table, nil
+}
+
+// withTimeout は timeout が指定されている場合にタイムアウト付きのコンテキストを返します
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// isTargetSheet はシートがプレフィックスに一致し、無視シートに含まれない場合に true を返します
+func isTargetSheet(sheet, prefix string, ignore []string) bool {
+	return !slices.Contains(ignore, sheet) && strings.HasPrefix(sheet, prefix)
+}
+
 // Compare はExcelの期待結果と実際にデータベースに登録されているデータを比較して
 // 差分がある場合は報告します。
-// 値の比較は go-cmp (https://github.com/google/go-cmp) を利用しています。
-func (e *exceltesing) Compare(t *testing.T, r CompareRequest) bool {
+// 行は主キー (または CompareRequest.KeyColumns) で突き合わせ、期待値にのみ存在する行、
+// データベースにのみ存在する行、値が異なるセルをA列の番号とともに報告します。
+func (e *exceltesing) Compare(t TestingT, r CompareRequest) bool {
+	t.Helper()
+
+	res, err := e.Diff(context.Background(), r)
+	if err != nil {
+		t.Errorf("%v", err)
+		return false
+	}
+	return reportCompareResult(t, r, res)
+}
+
+// CompareEventually は Compare と同様に比較しますが、一致するまで interval の間隔で比較をやり直します。
+// 非同期に処理するワーカーなどにより、最終的に期待する状態になるデータベースの検証に利用します。
+//
+// timeout を過ぎても一致しない場合は、最後に比較した結果の差分のみを報告します。
+// レポートの出力や期待値の更新、CSVの出力は最後に比較した結果に対してのみ行います。
+// Bookの読み込みやトランザクションの開始に失敗した場合は、やり直さずに失敗とします。
+func (e *exceltesing) CompareEventually(t TestingT, r CompareRequest, timeout, interval time.Duration) bool {
+	t.Helper()
+
+	deadline := time.Now().Add(timeout)
+	var (
+		res *CompareResult
+		err error
+	)
+	for {
+		res, err = e.diffBookInTx(context.Background(), r)
+		if err != nil || res.Equal() || time.Now().Add(interval).After(deadline) {
+			break
+		}
+		time.Sleep(interval)
+	}
+	if err == nil {
+		err = e.writeOutputs(r, res)
+	}
+	if err != nil {
+		t.Errorf("%v", err)
+		return false
+	}
+	return reportCompareResult(t, r, res)
+}
+
+// reportCompareResult は比較結果 res のシートごとの失敗や差分を t に報告します
+func reportCompareResult(t TestingT, r CompareRequest, res *CompareResult) bool {
 	t.Helper()
 
-	equal, errors := e.CompareWithContext(context.Background(), r)
-	for _, err := range errors {
-		t.Error(err)
+	equal := true
+	for _, d := range res.Tables {
+		switch {
+		case d.Err != nil:
+			t.Errorf("%v", d.Err)
+			equal = false
+		case d.Equal():
+		case res.Updated:
+			// 更新した差分は失敗とせずに記録する。反映しないマッチャーのセルは失敗とする
+			t.Logf("update %s: %s", r.TargetBookPath, d.String())
+			if len(d.NotUpdatedCells) > 0 {
+				t.Errorf("%s", d.notUpdatedString())
+				equal = false
+			}
+		default:
+			t.Errorf("%s", d.String())
+			equal = false
+		}
 	}
 
 	return equal
 }
 
-func (e *exceltesing) CompareWithContext(_ context.Context, r CompareRequest) (bool, []error) {
-	tx, err := e.db.Begin()
+func (e *exceltesing) CompareWithContext(ctx context.Context, r CompareRequest) (bool, []error) {
+	res, err := e.Diff(ctx, r)
+	if err != nil {
+		return false, []error{err}
+	}
+	return res.Equal(), res.Errors()
+}
+
+// CompareTx は呼び出し元のトランザクション tx 内でExcelのBookとデータベースの値を比較します。
+// コミットされていない tx の書き込みを、ロールバックする前に検証できます。
+//
+// 比較はセーブポイント内で行い、一時テーブルやセッション設定は比較の終了時にロールバックします。
+// ただし、MySQLのセッション設定はロールバックされません。
+func (e *exceltesing) CompareTx(ctx context.Context, tx *sql.Tx, r CompareRequest) (bool, []error) {
+	res, err := e.DiffTx(ctx, tx, r)
+	if err != nil {
+		return false, []error{err}
+	}
+	return res.Equal(), res.Errors()
+}
+
+// Diff はExcelのBookとデータベースの値を比較し、シートごとの差分を返します。
+// シート単位の失敗は TableDiff.Err に格納し、Bookやトランザクションの失敗の場合はエラーを返します。
+func (e *exceltesing) Diff(ctx context.Context, r CompareRequest) (*CompareResult, error) {
+	res, err := e.diffBookInTx(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	if err := e.writeOutputs(r, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// diffBookInTx は新しいトランザクション内で比較し、レポートの出力やBookの更新は行わずに差分を返します
+func (e *exceltesing) diffBookInTx(ctx context.Context, r CompareRequest) (*CompareResult, error) {
+	ctx, cancel := withTimeout(ctx, r.Timeout)
+	defer cancel()
+
+	tx, err := e.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: r.Mode == CompareModeReadOnly})
 	if err != nil {
-		return false, []error{fmt.Errorf("exceltesting: failed to start transaction: %w", err)}
+		return nil, fmt.Errorf("exceltesting: failed to start transaction: %w", err)
 	}
 	defer tx.Rollback()
 
+	restore, err := applySessionSettings(ctx, tx, detectDialect(e.db), r.SessionSettings)
+	defer restore()
+	if err != nil {
+		return nil, fmt.Errorf("exceltesting: failed to apply session settings: %w", err)
+	}
+
+	return e.diffBook(ctx, tx, r)
+}
+
+// DiffTx は CompareTx と同様に呼び出し元のトランザクション tx 内で比較し、シートごとの差分を返します
+func (e *exceltesing) DiffTx(ctx context.Context, tx *sql.Tx, r CompareRequest) (*CompareResult, error) {
+	ctx, cancel := withTimeout(ctx, r.Timeout)
+	defer cancel()
+
+	if _, err := tx.ExecContext(ctx, "SAVEPOINT "+savepointCompareTx); err != nil {
+		return nil, fmt.Errorf("exceltesting: failed to create savepoint: %w", err)
+	}
+	defer func() {
+		// ctx がキャンセルされていてもセーブポイントまで戻せるようにする
+		_, _ = tx.ExecContext(context.Background(), "ROLLBACK TO SAVEPOINT "+savepointCompareTx)
+		_, _ = tx.ExecContext(context.Background(), "RELEASE SAVEPOINT "+savepointCompareTx)
+	}()
+
+	restore, err := applySessionSettings(ctx, tx, detectDialect(e.db), r.SessionSettings)
+	defer restore()
+	if err != nil {
+		return nil, fmt.Errorf("exceltesting: failed to apply session settings: %w", err)
+	}
+
+	res, err := e.diffBook(ctx, tx, r)
+	if err != nil {
+		return nil, err
+	}
+	if err := e.writeOutputs(r, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// diffBook はトランザクション tx 内でExcelのBookの各シートとデータベースの値を比較します
+func (e *exceltesing) diffBook(ctx context.Context, tx *sql.Tx, r CompareRequest) (*CompareResult, error) {
 	f, err := excelize.OpenFile(r.TargetBookPath)
 	if err != nil {
-		return false, []error{fmt.Errorf("exceltesting: failed to open excel file: %w", err)}
+		return nil, fmt.Errorf("exceltesting: failed to open excel file: %w", err)
 	}
 	defer f.Close()
 
-	equal := true
-	var errs []error
-
+	res := &CompareResult{}
 	for _, sheet := range f.GetSheetList() {
-		if slices.Contains(r.IgnoreSheet, sheet) {
+		if isSQLSheet(f, sheet, r.SQLSheetPrefix) {
+			continue
+		}
+		if !isTargetSheet(sheet, r.SheetPrefix, r.IgnoreSheet) {
 			continue
 		}
-		if strings.HasPrefix(sheet, r.SheetPrefix) {
-			table, err := e.loadExcelSheet(f, sheet)
-			if err != nil {
-				errs = append(errs, fmt.Errorf("exceltesting: failed to load excel sheet, sheet = %s: %v", sheet, err))
-				equal = false
-				continue
-			}
-			got, want, err := e.comparativeSource(table, &r)
-			if err != nil {
-				errs = append(errs, fmt.Errorf("exceltesting: failed to fetch comparative source: %w", err))
-				equal = false
-				continue
-			}
 
-			opts := []cmp.Option{
-				cmpopts.EquateNaNs(),
-				cmp.Comparer(func(x, y *big.Int) bool {
-					return x.Cmp(y) == 0
-				}),
-				cmp.AllowUnexported(x{}),
-			}
-			if diff := cmp.Diff(want, got, opts...); diff != "" {
-				errs = append(errs, fmt.Errorf("table(%s) mismatch (-want +got):\n%s", table.name, diff))
-				equal = false
-				continue
-			}
+		table, err := e.loadExcelSheet(f, sheet)
+		if err != nil {
+			res.Tables = append(res.Tables, TableDiff{
+				Sheet: sheet,
+				Err:   fmt.Errorf("exceltesting: failed to load excel sheet, sheet = %s: %v", sheet, err),
+			})
+			continue
+		}
+		d, err := e.diffTableTx(ctx, tx, table, &r)
+		if err != nil {
+			res.Tables = append(res.Tables, TableDiff{
+				Table: table.name,
+				Sheet: sheet,
+				Err:   fmt.Errorf("exceltesting: failed to fetch comparative source: %w", err),
+			})
+			continue
+		}
+		res.Tables = append(res.Tables, *d)
+	}
+	return res, nil
+}
+
+// writeOutputs は比較結果 res に応じて、レポートの出力、期待値の更新、CSVの出力を行います
+func (e *exceltesing) writeOutputs(r CompareRequest, res *CompareResult) error {
+	if r.ReportPath != "" && !res.Equal() {
+		if err := writeReport(r.ReportPath, r.TargetBookPath, res); err != nil {
+			return fmt.Errorf("exceltesting: failed to write report: %w", err)
+		}
+	}
+
+	if isUpdate(r) {
+		updated, err := updateBook(r.TargetBookPath, res)
+		if err != nil {
+			return fmt.Errorf("exceltesting: failed to update excel file: %w", err)
 		}
+		res.Updated = updated
 	}
 
 	if r.EnableDumpCSV {
 		if err := e.dumpBookAsCSV(r.TargetBookPath); err != nil {
-			return false, []error{fmt.Errorf("dump csv: %w", err)}
+			return fmt.Errorf("dump csv: %w", err)
 		}
 	}
 
-	return equal, errs
+	return nil
 }
 
 // DumpCSV はExcelブックの全シートをCSVにDumpします。
@@ -174,17 +502,17 @@ func (e *exceltesing) CompareWithContext(_ context.Context, r CompareRequest) (b
 // CSVファイルをDumpします。
 //
 // Deprecated: LoadRequest.EnableDumpCSV や CompareRequest.EnableDumpCSV のオプションを利用してください
-func (e *exceltesing) DumpCSV(t *testing.T, r DumpRequest) {
+func (e *exceltesing) DumpCSV(t TestingT, r DumpRequest) {
 	t.Helper()
 
 	e.dumpCSV(t, r.TargetBookPaths...)
 }
 
-func (e *exceltesing) dumpCSV(t *testing.T, paths ...string) {
+func (e *exceltesing) dumpCSV(t TestingT, paths ...string) {
 	t.Helper()
 
 	if err := e.dumpBookAsCSV(paths...); err != nil {
-		t.Error(err)
+		t.Errorf("%v", err)
 	}
 }
 
@@ -263,11 +591,26 @@ type LoadRequest struct {
 	SheetPrefix string
 	// 無視シート
 	IgnoreSheet []string
+	// SQLSheetPrefix はSQLシートとして扱うシート名のプレフィックスです。
+	// A2セルに "#sql" が記載されたシートは本設定に関わらずSQLシートとして扱います
+	SQLSheetPrefix string
 	// EnableAutoCompleteNotNullColumn はExcel上でカラムの指定がない場合にデフォルト値で補完します
 	// カラムにNOT NULL制約がある場合のみ補完します
 	EnableAutoCompleteNotNullColumn bool
 	// EnableDumpCSV はExcelファイルをCSVファイルとしてDumpします
 	EnableDumpCSV bool
+	// SessionSettings はデータ投入のトランザクション内でのみ有効なセッション設定です
+	// (e.g. TimeZone, search_path, MySQLの場合は time_zone, sql_mode)
+	SessionSettings map[string]string
+	// Timeout はデータ投入全体のタイムアウトです。0の場合はタイムアウトしません
+	Timeout time.Duration
+	// DryRun が指定された場合、データベースへの投入は行わずに実行するSQLスクリプトを書き込みます
+	DryRun io.Writer
+	// Dialect は DryRun で書き込むSQLの方言です。未指定の場合はデータベースドライバから判定します
+	Dialect Dialect
+	// TruncateReferencingTables が指定された場合、投入するテーブルと、外部キーでそれらを参照するテーブルを投入前にまとめて空にします。
+	// 参照するテーブルはBookに含まれなくても空にします
+	TruncateReferencingTables bool
 }
 
 // CompareRequest はExcelとデータベースの値を比較するための設定です。
@@ -280,8 +623,37 @@ type CompareRequest struct {
 	IgnoreSheet []string
 	// 無視するカラム名
 	IgnoreColumns []string
+	// SQLSheetPrefix は比較対象から除くSQLシートのシート名のプレフィックスです。
+	// A2セルに "#sql" が記載されたシートは本設定に関わらずSQLシートとして扱います
+	SQLSheetPrefix string
+	// KeyColumns はテーブル名 (クエリシートの場合はシート名) ごとに、行を特定するカラム名を指定します。
+	// 主キーや一意インデックスがない外部テーブルなどで利用します
+	KeyColumns map[string][]string
 	// EnableDumpCSV はExcelファイルをCSVファイルとしてDumpします
 	EnableDumpCSV bool
+	// SessionSettings は比較のトランザクション内でのみ有効なセッション設定です
+	// (e.g. TimeZone, search_path, MySQLの場合は time_zone, sql_mode)
+	SessionSettings map[string]string
+	// Timeout は比較全体のタイムアウトです。0の場合はタイムアウトしません
+	Timeout time.Duration
+	// TimePrecision は日時を比較する精度です (e.g. time.Millisecond)。0の場合は切り捨てずに比較します
+	TimePrecision time.Duration
+	// Mode は比較する方式です。指定しない場合は CompareModeTempTable で比較します
+	Mode CompareMode
+	// Contains はシートに記載された行のみを比較します。データベースにのみ存在する行は差分として報告しません
+	Contains bool
+	// OrderBy はテーブル名 (クエリシートの場合はシート名) ごとに、主キーや一意インデックスがないテーブルの行の並び順を指定します。
+	// 指定した場合、シートには同じ並び順で行を記載します。指定しない場合は行の順序を無視して比較します
+	OrderBy map[string][]string
+	// ReportPath を指定すると、差分がある場合に期待値のBookをコピーし、
+	// 差分のあるセルや行を強調したレポートを保存します
+	ReportPath string
+	// Filters はテーブル名 (クエリシートの場合はシート名) ごとに、比較対象の行を絞り込むWHERE句の条件を指定します (e.g. "tenant_id = 42")。
+	// シートのカラム定義行より上のA列に "#where"、B列に条件を記載しても指定できます
+	Filters map[string]string
+	// Update は差分がある場合に、期待値のBookのデータ行をデータベースの値で上書きします。
+	// 環境変数 EXCELTESTING_UPDATE に true (e.g. 1) を指定した場合も更新します
+	Update bool
 }
 
 // DumpRequest はExcelをCSVにDumpするための設定です。
@@ -313,63 +685,133 @@ func (e *exceltesing) loadExcelSheet(f *excelize.File, targetSheet string) (*tab
 	if err != nil {
 		return nil, fmt.Errorf("get row: %w", err)
 	}
+	if len(rows) < columnDefineRowNum {
+		return nil, fmt.Errorf("column definition row %d not found", columnDefineRowNum)
+	}
 
 	columns := getExcelColumns(rows, columnDefineRowNum)
-	data, err := getExcelData(rows, columnDefineRowNum)
+	data, rowNums, err := getExcelData(rows, columnDefineRowNum)
 	if err != nil {
 		return nil, fmt.Errorf("get excel data: %w", err)
 	}
+	before, after := getHookStatements(rows, columnDefineRowNum)
 
-	return &table{
+	t := &table{
+		sheet:   targetSheet,
 		name:    tableNm,
 		columns: columns,
 		data:    data,
-	}, nil
+		rowNums: rowNums,
+		before:  before,
+		after:   after,
+		where:   getWhereCondition(rows, columnDefineRowNum),
+	}
+	if isQuery(tableNm) {
+		t.name = ""
+		t.query = strings.TrimSuffix(strings.TrimSpace(tableNm), ";")
+	}
+	return t, nil
 }
 
-// comparativeSource はデータベースに格納されている実際のテーブルの値と、Excelから取得した期待する結果の値を
-// 比較可能な値として取得します。
-func (e *exceltesing) comparativeSource(t *table, req *CompareRequest) ([][]x, [][]x, error) {
-	pk, err := e.getPrimaryKeyColumns(t.name)
+// diffTableTx はセーブポイント内で comparativeSource を実行し、テーブルの差分を返します。
+// 失敗した場合はセーブポイントまでロールバックし、後続のシートの比較を継続できるようにします。
+func (e *exceltesing) diffTableTx(ctx context.Context, tx *sql.Tx, t *table, req *CompareRequest) (*TableDiff, error) {
+	if _, err := tx.ExecContext(ctx, "SAVEPOINT "+savepointCompare); err != nil {
+		return nil, fmt.Errorf("savepoint: %w", err)
+	}
+	want, got, mt, err := e.comparativeSource(ctx, tx, t, req)
 	if err != nil {
-		return nil, nil, err
+		_, _ = tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+savepointCompare)
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx, "RELEASE SAVEPOINT "+savepointCompare); err != nil {
+		return nil, fmt.Errorf("release savepoint: %w", err)
 	}
 
-	q1, cs, err := e.buildComparingQuery(t, pk, req)
+	d, err := mt.diff(want, got)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
+	}
+	d.Table = t.name
+	d.Sheet = t.sheet
+	if req.Contains {
+		d.ExtraRows = nil
+	}
+	return d, nil
+}
+
+// comparativeSource はExcelから取得した期待する結果の値と、データベースに格納されている実際のテーブルの値を
+// 比較可能な値として取得します。あわせて行を突き合わせる方法を返します。
+func (e *exceltesing) comparativeSource(ctx context.Context, q queryer, t *table, req *CompareRequest) ([]compareRow, []compareRow, matching, error) {
+	if t.query != "" {
+		return e.comparativeSourceQuery(ctx, q, t, req)
+	}
+	if req.Mode == CompareModeReadOnly {
+		return e.comparativeSourceReadOnly(ctx, q, t, req)
 	}
 
-	got, err := e.getComparingData(q1, len(cs))
+	mt, err := e.rowMatching(ctx, q, t.name, req)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, matching{}, err
+	}
+
+	q1, cs, err := e.buildComparingQuery(t, mt.orderClause(), req)
+	if err != nil {
+		return nil, nil, matching{}, err
+	}
+
+	got, types, err := e.getComparingData(ctx, q, q1, len(cs))
+	if err != nil {
+		return nil, nil, matching{}, err
 	}
 
-	if err := e.createTempTable(t.name); err != nil {
-		return nil, nil, fmt.Errorf("create temporary table: %w", err)
+	m, matchers, err := extractMatchers(t)
+	if err != nil {
+		return nil, nil, matching{}, err
+	}
+
+	if err := e.createTempTable(ctx, q, t.name); err != nil {
+		return nil, nil, matching{}, fmt.Errorf("create temporary table: %w", err)
 	}
 
-	c := t.DeepCopy()
+	// 期待値の行とExcelの行を対応付けるため、一時テーブルには行の位置も投入する
+	c := m.DeepCopy()
 	c.name = tempTablePrefix + c.name
-	if err := e.insertData(&c); err != nil {
-		return nil, nil, fmt.Errorf("insert data to %s: %w", c.name, err)
+	c.where = ""
+	c.columns = append(c.columns, rowNumColumn)
+	for i := range c.data {
+		c.data[i] = append(c.data[i], strconv.Itoa(i))
+	}
+	if err := e.insertData(ctx, q, &c); err != nil {
+		return nil, nil, matching{}, fmt.Errorf("insert data to %s: %w", c.name, err)
 	}
 
-	q2, _, err := e.buildComparingQuery(&c, pk, req)
+	q2, _, err := e.buildComparingQuery(&c, rowNumColumn, req)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, matching{}, err
 	}
 
-	want, err := e.getComparingData(q2, len(cs))
+	want, _, err := e.getComparingData(ctx, q, q2, len(cs)+1)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, matching{}, err
+	}
+
+	idxs := make([]int, 0, len(want))
+	for i, w := range want {
+		n, err := strconv.Atoi(valueString(w[len(cs)]))
+		if err != nil {
+			return nil, nil, matching{}, fmt.Errorf("row number: %w", err)
+		}
+		idxs = append(idxs, n)
+		want[i] = w[:len(cs)]
 	}
 
-	return convert(got, cs), convert(want, cs), nil
+	opt := normalizeOption{timePrecision: req.TimePrecision}
+	return wantRows(t, convert(want, cs, types, opt), idxs, matchers), toCompareRows(convert(got, cs, types, opt), nil), mt, nil
 }
 
-func (e *exceltesing) insertData(t *table) error {
-	if _, err := e.db.ExecContext(context.TODO(), fmt.Sprintf(`TRUNCATE TABLE %s;`, t.name)); err != nil {
+func (e *exceltesing) insertData(ctx context.Context, q queryer, t *table) error {
+	if _, err := q.ExecContext(ctx, fmt.Sprintf(`TRUNCATE TABLE %s;`, t.name)); err != nil {
 		return fmt.Errorf("truncate table %s: %w", t.name, err)
 	}
 
@@ -378,23 +820,63 @@ func (e *exceltesing) insertData(t *table) error {
 	}
 
 	insertSQL := t.buildInsertSQL()
-	_, err := e.db.ExecContext(context.TODO(), insertSQL)
+	_, err := q.ExecContext(ctx, insertSQL)
 	return err
 }
 
-func (e *exceltesing) createTempTable(tableName string) error {
-	// PostgreSQL 互換
-	queryPG := fmt.Sprintf("CREATE TEMP TABLE IF NOT EXISTS %s AS SELECT * FROM %s WHERE 0 = 1;", tempTablePrefix+tableName, tableName)
-	if _, err := e.db.Exec(queryPG); err == nil {
+// insertDataTx はトランザクション内でデータを投入します。
+// 投入に失敗した場合はセーブポイント内で1行ずつ再投入し、原因となったセルを *CellError として返します。
+// MySQLはエラーメッセージに行の番号が含まれる場合のみ *CellError を返します。
+func (e *exceltesing) insertDataTx(ctx context.Context, tx *sql.Tx, t *table, truncate bool) error {
+	if truncate {
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf(`TRUNCATE TABLE %s;`, t.name)); err != nil {
+			return fmt.Errorf("truncate table %s: %w", t.name, err)
+		}
+	}
+
+	if len(t.data) == 0 {
+		return nil
+	}
+
+	if detectDialect(e.db) == DialectMySQL {
+		if _, err := tx.ExecContext(ctx, t.buildInsertSQL()); err != nil {
+			if ce := mysqlCellError(t, err); ce != nil {
+				return ce
+			}
+			return err
+		}
 		return nil
 	}
+
+	if _, err := tx.ExecContext(ctx, "SAVEPOINT "+savepointInsert); err != nil {
+		return fmt.Errorf("savepoint: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, t.buildInsertSQL()); err != nil {
+		if _, rerr := tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+savepointInsert); rerr != nil {
+			return err
+		}
+		return e.locateCellError(ctx, tx, t, err)
+	}
+	if _, err := tx.ExecContext(ctx, "RELEASE SAVEPOINT "+savepointInsert); err != nil {
+		return fmt.Errorf("release savepoint: %w", err)
+	}
+	return nil
+}
+
+func (e *exceltesing) createTempTable(ctx context.Context, q queryer, tableName string) error {
+	// PostgreSQL 互換
+	queryPG := fmt.Sprintf("CREATE TEMP TABLE IF NOT EXISTS %s AS SELECT *, 0 AS %s FROM %s WHERE 0 = 1;", tempTablePrefix+tableName, rowNumColumn, tableName)
+	_, err := q.ExecContext(ctx, queryPG)
+	if err == nil || ctx.Err() != nil {
+		return err
+	}
 	// MySQL 互換
-	queryMySQL := fmt.Sprintf("CREATE TEMPORARY TABLE IF NOT EXISTS %s AS SELECT * FROM %s WHERE 0 = 1;", tempTablePrefix+tableName, tableName)
-	_, err := e.db.Exec(queryMySQL)
+	queryMySQL := fmt.Sprintf("CREATE TEMPORARY TABLE IF NOT EXISTS %s AS SELECT *, 0 AS %s FROM %s WHERE 0 = 1;", tempTablePrefix+tableName, rowNumColumn, tableName)
+	_, err = q.ExecContext(ctx, queryMySQL)
 	return err
 }
 
-func (e *exceltesing) buildComparingQuery(t *table, primaryKey string, req *CompareRequest) (string, []string, error) {
+func (e *exceltesing) buildComparingQuery(t *table, orderBy string, req *CompareRequest) (string, []string, error) {
 	columns := make([]string, 0, len(t.columns))
 	for _, c := range t.columns {
 		if slices.Contains(req.IgnoreColumns, c) {
@@ -411,33 +893,65 @@ func (e *exceltesing) buildComparingQuery(t *table, primaryKey string, req *Comp
 		}
 		querySQL += column
 	}
-	querySQL += fmt.Sprintf(" FROM %s ORDER BY %s;", t.name, primaryKey)
+	if t.query != "" {
+		querySQL += " FROM (" + t.query + ") exceltesting_query"
+	} else {
+		querySQL += " FROM " + t.name
+	}
+	if where := comparingCondition(t, req); where != "" {
+		querySQL += " WHERE " + where
+	}
+	if orderBy != "" {
+		querySQL += " ORDER BY " + orderBy
+	}
+	querySQL += ";"
 	return querySQL, columns, nil
 }
 
-func (e *exceltesing) getComparingData(q string, len int) ([][]any, error) {
+// comparingCondition は CompareRequest.Filters とシートの "#where" に記載された比較対象の行の条件を返します
+func comparingCondition(t *table, req *CompareRequest) string {
+	var conds []string
+	for _, c := range []string{req.Filters[t.optionKey()], t.where} {
+		if c = strings.TrimSpace(c); c != "" {
+			conds = append(conds, "("+c+")")
+		}
+	}
+	return strings.Join(conds, " AND ")
+}
+
+// getComparingData はクエリの結果と、各カラムのデータベースの型名を返します
+func (e *exceltesing) getComparingData(ctx context.Context, q queryer, query string, n int) ([][]any, []string, error) {
 	var got [][]any
 
-	rows, err := e.db.Query(q)
+	rows, err := q.QueryContext(ctx, query)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	defer rows.Close()
 
+	cts, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, nil, err
+	}
+	types := make([]string, n)
+	for i := 0; i < n && i < len(cts); i++ {
+		types[i] = cts[i].DatabaseTypeName()
+	}
+
 	for rows.Next() {
-		g := make([]any, len)
+		g := make([]any, n)
 		for i := range g {
 			g[i] = &g[i]
 		}
 		if err := rows.Scan(g...); err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		got = append(got, g)
 	}
 	if err := rows.Err(); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	return got, nil
+	return got, types, nil
 }
 
 type dbColumn struct {
@@ -446,11 +960,14 @@ type dbColumn struct {
 	data     string
 }
 
-func (e *exceltesing) tableColumns(tableName string) ([]dbColumn, error) {
+func (e *exceltesing) tableColumns(ctx context.Context, q queryer, tableName string) ([]dbColumn, error) {
 	var columns []dbColumn
 	// まずはPostgreSQL向けクエリで試行
-	rows, err := e.db.Query(getTableNotNullColumns, tableName)
+	rows, err := q.QueryContext(ctx, getTableNotNullColumns, tableName)
 	if err != nil {
+		if ctx.Err() != nil {
+			return nil, err
+		}
 		// MySQL向けのクエリにフォールバック
 		const mysqlNotNullQuery = `
 SELECT
@@ -462,7 +979,7 @@ WHERE table_schema = DATABASE()
   AND is_nullable = 'NO'
   AND column_default IS NULL
 ORDER BY ordinal_position;`
-		rows, err = e.db.Query(mysqlNotNullQuery, tableName)
+		rows, err = q.QueryContext(ctx, mysqlNotNullQuery, tableName)
 		if err != nil {
 			return nil, err
 		}
@@ -482,27 +999,51 @@ ORDER BY ordinal_position;`
 	return columns, nil
 }
 
+// rowMatching は比較時に行を突き合わせる方法を返します。
+// CompareRequest.KeyColumns 、主キー、一意インデックスの順にキーとなるカラムを探し、
+// いずれもない場合は CompareRequest.OrderBy の並び順、またはすべてのカラムの値で突き合わせます。
+func (e *exceltesing) rowMatching(ctx context.Context, q queryer, tableName string, req *CompareRequest) (matching, error) {
+	if cs := req.KeyColumns[tableName]; len(cs) > 0 {
+		return matching{keys: cs}, nil
+	}
+	pk, err := e.getPrimaryKeyColumns(ctx, q, tableName)
+	if errors.Is(err, errPrimaryKeyNotFound) {
+		return matching{orderBy: req.OrderBy[tableName]}, nil
+	}
+	if err != nil {
+		return matching{}, err
+	}
+	return matching{keys: strings.Split(pk, ",")}, nil
+}
+
 // getPrimaryKeyColumns は主キー列名をカンマ区切りで返します（複合主キー対応）
-func (e *exceltesing) getPrimaryKeyColumns(tableName string) (string, error) {
-	// PostgreSQL向けクエリを試行
-	var pk string
-	if err := e.db.QueryRow(getPrimaryKeyQuery, tableName).Scan(&pk); err == nil && pk != "" {
-		return pk, nil
-	}
-	// MySQL向けクエリにフォールバック
-	const mysqlPKQuery = `
-SELECT GROUP_CONCAT(column_name ORDER BY ordinal_position SEPARATOR ',') AS column_names
-FROM information_schema.KEY_COLUMN_USAGE
-WHERE table_schema = DATABASE()
-  AND table_name = ?
-  AND constraint_name = 'PRIMARY';`
-	if err := e.db.QueryRow(mysqlPKQuery, tableName).Scan(&pk); err != nil {
+// 主キーがない場合は一意インデックスの列名を返します。
+func (e *exceltesing) getPrimaryKeyColumns(ctx context.Context, q queryer, tableName string) (string, error) {
+	query := getPrimaryKeyQuery
+	if detectDialect(e.db) == DialectMySQL {
+		query = `
+SELECT GROUP_CONCAT(k.column_name ORDER BY k.ordinal_position SEPARATOR ',') AS column_names
+FROM information_schema.TABLE_CONSTRAINTS c
+JOIN information_schema.KEY_COLUMN_USAGE k
+  ON k.constraint_schema = c.constraint_schema
+  AND k.constraint_name = c.constraint_name
+  AND k.table_name = c.table_name
+WHERE c.table_schema = DATABASE()
+  AND c.table_name = ?
+  AND c.constraint_type IN ('PRIMARY KEY', 'UNIQUE')
+GROUP BY c.constraint_name, c.constraint_type
+ORDER BY c.constraint_type = 'PRIMARY KEY' DESC, COUNT(*), c.constraint_name
+LIMIT 1;`
+	}
+
+	var pk sql.NullString
+	if err := q.QueryRowContext(ctx, query, tableName).Scan(&pk); err != nil && !errors.Is(err, sql.ErrNoRows) {
 		return "", err
 	}
-	if strings.TrimSpace(pk) == "" {
-		return "", fmt.Errorf("primary key not found: %s", tableName)
+	if strings.TrimSpace(pk.String) == "" {
+		return "", fmt.Errorf("%w: %s", errPrimaryKeyNotFound, tableName)
 	}
-	return pk, nil
+	return pk.String, nil
 }
 
 func getExcelColumns(rows [][]string, rowNum int) []string {
@@ -521,11 +1062,16 @@ func getExcelColumns(rows [][]string, rowNum int) []string {
 	return columns
 }
 
-func getExcelData(rows [][]string, rowNum int) ([][]string, error) {
+// getExcelData はデータ行と、各行に対応するA列の番号を返します。
+// A列が数値でない場合はExcelの行番号を用います。
+func getExcelData(rows [][]string, rowNum int) ([][]string, []int, error) {
 	columns := getExcelColumns(rows, rowNum)
 
-	var data [][]string
-	for _, row := range rows[rowNum:] {
+	var (
+		data    [][]string
+		rowNums []int
+	)
+	for i, row := range rows[rowNum:] {
 		rowStr := ""
 		for _, cell := range row {
 			rowStr = rowStr + strings.Trim(strings.Trim(cell, "　"), " ")
@@ -548,8 +1094,14 @@ func getExcelData(rows [][]string, rowNum int) ([][]string, error) {
 			}
 		}
 		data = append(data, padded)
+
+		no, err := strconv.Atoi(strings.TrimSpace(row[0]))
+		if err != nil {
+			no = rowNum + i + 1
+		}
+		rowNums = append(rowNums, no)
 	}
-	return data, nil
+	return data, rowNums, nil
 }
 
 func getFileNameWithoutExt(path string) string {
@@ -559,27 +1111,37 @@ func getFileNameWithoutExt(path string) string {
 }
 
 // x はDBの値にカラムを付与した構造体です。
-// 比較出力を簡潔にするため、column/value は string に正規化します。
+// 比較出力を簡潔にするため、column/value はカラムの型に応じて string に正規化します。
 type x struct {
 	column string
 	value  string
+	// null は値がNULLの場合に true です
+	null bool
+}
+
+// toCompareRows は値に期待値のA列の番号 nums を付与します。nums が nil の場合はDBから取得した行とみなします
+func toCompareRows(vs [][]x, nums []int) []compareRow {
+	rows := make([]compareRow, 0, len(vs))
+	for i, v := range vs {
+		r := compareRow{values: v}
+		if i < len(nums) {
+			r.num = nums[i]
+		}
+		rows = append(rows, r)
+	}
+	return rows
 }
 
-func convert(vs [][]any, columns []string) [][]x {
+// convert はDBの値をカラムの型 types に応じて正規化します
+func convert(vs [][]any, columns []string, types []string, opt normalizeOption) [][]x {
 	resp := make([][]x, len(vs))
 	for i, r := range vs {
 		for j, v := range r {
-			// すべて文字列へ正規化
-			var s string
-			switch t := v.(type) {
-			case nil:
-				s = ""
-			case []byte:
-				s = string(t)
-			default:
-				s = fmt.Sprint(t)
+			var kind valueKind
+			if j < len(types) {
+				kind = kindOfDBType(types[j])
 			}
-			resp[i] = append(resp[i], x{column: columns[j], value: s})
+			resp[i] = append(resp[i], x{column: columns[j], value: normalizeValue(v, kind, opt), null: v == nil})
 		}
 	}
 	return resp
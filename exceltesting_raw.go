@@ -1,9 +1,17 @@
 package exceltesting
 
-import "database/sql"
+import (
+	"context"
+	"database/sql"
+)
 
 // LoadRaw はGoの値からデータベースにデータを投入します。コミットは行いません。
 func LoadRaw(tx *sql.Tx, r LoadRawRequest) error {
+	return LoadRawWithContext(context.Background(), tx, r)
+}
+
+// LoadRawWithContext は LoadRaw のコンテキストを受け取るバージョンです。
+func LoadRawWithContext(ctx context.Context, tx *sql.Tx, r LoadRawRequest) error {
 	t := &table{
 		name:    r.TableName,
 		columns: r.Columns,
@@ -11,7 +19,7 @@ func LoadRaw(tx *sql.Tx, r LoadRawRequest) error {
 	}
 
 	query := t.buildInsertSQL()
-	_, err := tx.Exec(query)
+	_, err := tx.ExecContext(ctx, query)
 	return err
 }
 
@@ -1,11 +1,14 @@
 package exceltesting
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"net"
 	"os"
 	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -15,6 +18,7 @@ import (
 	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/jackc/pgtype"
 	_ "github.com/jackc/pgx/v4/stdlib"
+	"github.com/xuri/excelize/v2"
 )
 
 func Test_exceltesing_Load(t *testing.T) {
@@ -167,6 +171,185 @@ func Test_exceltesing_Load(t *testing.T) {
 	}
 }
 
+func Test_exceltesing_LoadWithContext_CellError(t *testing.T) {
+	conn := testonly.OpenTestDB(t)
+	t.Cleanup(func() { conn.Close() })
+
+	testonly.ExecSQLFile(t, conn, filepath.Join("testdata", "schema", "ddl.sql"))
+
+	e := New(conn)
+	err := e.LoadWithContext(context.Background(), LoadRequest{
+		TargetBookPath: filepath.Join("testdata", "load_error.xlsx"),
+	})
+
+	var got *CellError
+	if !errors.As(err, &got) {
+		t.Fatalf("LoadWithContext() should return *CellError but %v", err)
+	}
+
+	want := &CellError{Sheet: "会社", Row: 2, Column: "founded_year", Value: "abc"}
+	opts := []cmp.Option{cmpopts.IgnoreFields(CellError{}, "Err")}
+	if diff := cmp.Diff(want, got, opts...); diff != "" {
+		t.Errorf("CellError mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func Test_exceltesing_Load_SQLSheet(t *testing.T) {
+	conn := testonly.OpenTestDB(t)
+	t.Cleanup(func() { conn.Close() })
+
+	testonly.ExecSQLFile(t, conn, filepath.Join("testdata", "schema", "ddl.sql"))
+
+	e := New(conn)
+	e.Load(t, LoadRequest{
+		TargetBookPath: filepath.Join("testdata", "load_sql.xlsx"),
+	})
+
+	type company struct {
+		CompanyCD   string
+		CompanyName string
+		Revision    int
+	}
+	rows, err := conn.Query(`SELECT company_cd, company_name, revision FROM company ORDER BY company_cd;`)
+	if err != nil {
+		t.Fatalf("failed to query company: %v", err)
+	}
+	defer rows.Close()
+	var got []company
+	for rows.Next() {
+		var c company
+		if err := rows.Scan(&c.CompanyCD, &c.CompanyName, &c.Revision); err != nil {
+			t.Fatalf("failed to scan company: %v", err)
+		}
+		got = append(got, c)
+	}
+
+	want := []company{
+		{CompanyCD: "00001", CompanyName: "Future Inc.", Revision: 1},
+		{CompanyCD: "00002", CompanyName: "YDC", Revision: 2},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("got rows for table(company) mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func Test_exceltesing_WithContext_Canceled(t *testing.T) {
+	conn := testonly.OpenTestDB(t)
+	t.Cleanup(func() { conn.Close() })
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		timeout time.Duration
+		want    error
+	}{
+		{
+			name: "canceled",
+			ctx:  canceled,
+			want: context.Canceled,
+		},
+		{
+			name:    "timeout",
+			ctx:     context.Background(),
+			timeout: time.Nanosecond,
+			want:    context.DeadlineExceeded,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := New(conn)
+
+			err := e.LoadWithContext(tt.ctx, LoadRequest{
+				TargetBookPath: filepath.Join("testdata", "load_example.xlsx"),
+				Timeout:        tt.timeout,
+			})
+			if !errors.Is(err, tt.want) {
+				t.Errorf("LoadWithContext() error = %v, want %v", err, tt.want)
+			}
+
+			equal, errs := e.CompareWithContext(tt.ctx, CompareRequest{
+				TargetBookPath: filepath.Join("testdata", "compare.xlsx"),
+				Timeout:        tt.timeout,
+			})
+			if equal || len(errs) != 1 || !errors.Is(errs[0], tt.want) {
+				t.Errorf("CompareWithContext() = %v, %v, want %v", equal, errs, tt.want)
+			}
+		})
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	tests := []struct {
+		name    string
+		book    string
+		dialect Dialect
+		want    string
+	}{
+		{
+			name:    "postgres",
+			book:    filepath.Join("testdata", "load_example.xlsx"),
+			dialect: DialectPostgres,
+			want: `BEGIN;
+
+-- sheet: 会社
+TRUNCATE TABLE company;
+INSERT INTO company (company_cd,company_name,founded_year,created_at,updated_at,revision) VALUES('00001', 'Future', '1989', current_timestamp, current_timestamp, '1'),('00002', 'YDC', '1972', current_timestamp, current_timestamp, '1');
+
+COMMIT;
+`,
+		},
+		{
+			name:    "mysql",
+			book:    filepath.Join("testdata", "load_example.xlsx"),
+			dialect: DialectMySQL,
+			want: `START TRANSACTION;
+
+-- sheet: 会社
+TRUNCATE TABLE company;
+INSERT INTO company (company_cd,company_name,founded_year,created_at,updated_at,revision) VALUES('00001', 'Future', '1989', current_timestamp, current_timestamp, '1'),('00002', 'YDC', '1972', current_timestamp, current_timestamp, '1');
+
+COMMIT;
+`,
+		},
+		{
+			name:    "sql sheet and hooks",
+			book:    filepath.Join("testdata", "load_sql.xlsx"),
+			dialect: DialectPostgres,
+			want: `BEGIN;
+
+-- sheet: 会社
+TRUNCATE TABLE company;
+INSERT INTO company (company_cd,company_name,founded_year,created_at,updated_at,revision) VALUES('00001', 'Future', '1989', current_timestamp, current_timestamp, '1'),('00002', 'YDC', '1972', current_timestamp, current_timestamp, '1');
+UPDATE company SET revision = 2 WHERE company_cd = '00002';
+
+-- sheet: 後処理
+UPDATE company SET company_name = 'Future Inc.' WHERE company_cd = '00001';
+
+COMMIT;
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b strings.Builder
+			err := DryRun(LoadRequest{
+				TargetBookPath: tt.book,
+				DryRun:         &b,
+				Dialect:        tt.dialect,
+			})
+			if err != nil {
+				t.Fatalf("DryRun() error = %v", err)
+			}
+			if diff := cmp.Diff(tt.want, b.String()); diff != "" {
+				t.Errorf("DryRun() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
 func Test_exceltesing_Compare(t *testing.T) {
 	conn := testonly.OpenTestDB(t)
 	defer conn.Close()
@@ -291,19 +474,317 @@ func Test_exceltesing_Compare(t *testing.T) {
 			wantSheet: "気温",
 			equal:     true,
 		},
+		{
+			name: "inherited table without own primary key",
+			input: func(t *testing.T) {
+				t.Helper()
+				tdb := testonly.OpenTestDB(t)
+				defer tdb.Close()
+				if _, err := tdb.Exec(`TRUNCATE temperature_log_2022;`); err != nil {
+					t.Fatal(err)
+				}
+				if _, err := tdb.Exec(`INSERT INTO temperature_log_2022 (ymd,value)
+						VALUES ('20220831',36.2),('20220228',-1.5);`); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantFile:  filepath.Join("testdata", "compare.xlsx"),
+			wantSheet: "継承気温",
+			equal:     true,
+		},
 	}
 	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			tt.input(t)
+		for _, mode := range []CompareMode{CompareModeTempTable, CompareModeReadOnly} {
+			t.Run(tt.name+"/"+string(mode), func(t *testing.T) {
+				tt.input(t)
 
-			e := New(conn)
-			got := e.Compare(mockT, CompareRequest{
+				e := New(conn)
+				got := e.Compare(mockT, CompareRequest{
+					TargetBookPath: filepath.Join("testdata", "compare.xlsx"),
+					SheetPrefix:    tt.wantSheet,
+					IgnoreSheet:    nil,
+					IgnoreColumns:  []string{"created_at", "updated_at"},
+					Mode:           mode,
+				})
+
+				if got != tt.equal {
+					t.Errorf("Compare() should return %v but %v", tt.equal, got)
+				}
+			})
+		}
+	}
+}
+
+func Test_exceltesing_Compare_Matcher(t *testing.T) {
+	conn := testonly.OpenTestDB(t)
+	defer conn.Close()
+
+	testonly.ExecSQLFile(t, conn, filepath.Join("testdata", "schema", "ddl.sql"))
+
+	// Even if there is a difference in Compare(), t.Errorf() prevents the test from failing.
+	mockT := new(testing.T)
+
+	tests := []struct {
+		name  string
+		input string
+		equal bool
+	}{
+		{
+			name: "matched",
+			input: `INSERT INTO company (company_cd,company_name,founded_year,created_at,updated_at,revision)
+				VALUES ('00001','Future',1989,current_timestamp,current_timestamp,1),('00002','YDC',1972,current_timestamp,current_timestamp,1);`,
+			equal: true,
+		},
+		{
+			name: "unmatched",
+			input: `INSERT INTO company (company_cd,company_name,founded_year,created_at,updated_at,revision)
+				VALUES ('00001','YDC',1800,current_timestamp - interval '1 day',current_timestamp,3),('00002','YDC',1973,current_timestamp,current_timestamp,2);`,
+			equal: false,
+		},
+	}
+	for _, tt := range tests {
+		for _, mode := range []CompareMode{CompareModeTempTable, CompareModeReadOnly} {
+			t.Run(tt.name+"/"+string(mode), func(t *testing.T) {
+				if _, err := conn.Exec(`TRUNCATE company;`); err != nil {
+					t.Fatal(err)
+				}
+				if _, err := conn.Exec(tt.input); err != nil {
+					t.Fatal(err)
+				}
+
+				e := New(conn)
+				got := e.Compare(mockT, CompareRequest{
+					TargetBookPath: filepath.Join("testdata", "compare_matcher.xlsx"),
+					Mode:           mode,
+				})
+				if got != tt.equal {
+					t.Errorf("Compare() should return %v but %v", tt.equal, got)
+				}
+			})
+		}
+	}
+}
+
+func Test_exceltesing_Compare_Partial(t *testing.T) {
+	conn := testonly.OpenTestDB(t)
+	defer conn.Close()
+
+	testonly.ExecSQLFile(t, conn, filepath.Join("testdata", "schema", "ddl.sql"))
+
+	if _, err := conn.Exec(`TRUNCATE company;`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.Exec(`INSERT INTO company (company_cd,company_name,founded_year,created_at,updated_at,revision)
+		VALUES ('00001','Future',1989,current_timestamp,current_timestamp,1),('00002','YDC',1972,current_timestamp,current_timestamp,1),('00003','FutureOne',2002,current_timestamp,current_timestamp,1);`); err != nil {
+		t.Fatal(err)
+	}
+
+	// Even if there is a difference in Compare(), t.Errorf() prevents the test from failing.
+	mockT := new(testing.T)
+
+	tests := []struct {
+		name  string
+		req   CompareRequest
+		equal bool
+	}{
+		{
+			name: "unrelated rows",
+			req: CompareRequest{
 				TargetBookPath: filepath.Join("testdata", "compare.xlsx"),
-				SheetPrefix:    tt.wantSheet,
-				IgnoreSheet:    nil,
+				SheetPrefix:    "会社",
 				IgnoreColumns:  []string{"created_at", "updated_at"},
+			},
+			equal: false,
+		},
+		{
+			name: "contains",
+			req: CompareRequest{
+				TargetBookPath: filepath.Join("testdata", "compare.xlsx"),
+				SheetPrefix:    "会社",
+				IgnoreColumns:  []string{"created_at", "updated_at"},
+				Contains:       true,
+			},
+			equal: true,
+		},
+		{
+			name: "filters",
+			req: CompareRequest{
+				TargetBookPath: filepath.Join("testdata", "compare.xlsx"),
+				SheetPrefix:    "会社",
+				IgnoreColumns:  []string{"created_at", "updated_at"},
+				Filters:        map[string]string{"company": "founded_year < 2000"},
+			},
+			equal: true,
+		},
+		{
+			name: "where in sheet",
+			req: CompareRequest{
+				TargetBookPath: filepath.Join("testdata", "compare_where.xlsx"),
+			},
+			equal: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := New(conn)
+			if got := e.Compare(mockT, tt.req); got != tt.equal {
+				t.Errorf("Compare() should return %v but %v", tt.equal, got)
+			}
+		})
+	}
+}
+
+func Test_exceltesing_buildComparingQuery(t *testing.T) {
+	tests := []struct {
+		name string
+		t    *table
+		req  *CompareRequest
+		want string
+	}{
+		{
+			name: "all rows",
+			t:    &table{name: "company", columns: []string{"company_cd", "company_name", "updated_at"}},
+			req:  &CompareRequest{IgnoreColumns: []string{"updated_at"}},
+			want: "SELECT company_cd, company_name FROM company ORDER BY company_cd;",
+		},
+		{
+			name: "filters and where in sheet",
+			t:    &table{name: "company", columns: []string{"company_cd"}, where: "founded_year < 2000"},
+			req:  &CompareRequest{Filters: map[string]string{"company": "tenant_id = 42", "other": "x = 1"}},
+			want: "SELECT company_cd FROM company WHERE (tenant_id = 42) AND (founded_year < 2000) ORDER BY company_cd;",
+		},
+		{
+			name: "query sheet",
+			t:    &table{sheet: "会社ごと", columns: []string{"company_cd", "events"}, query: "SELECT company_cd, count(*) AS events FROM audit_log GROUP BY company_cd"},
+			req:  &CompareRequest{Filters: map[string]string{"会社ごと": "events > 1"}},
+			want: "SELECT company_cd, events FROM (SELECT company_cd, count(*) AS events FROM audit_log GROUP BY company_cd) exceltesting_query WHERE (events > 1) ORDER BY company_cd;",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := &exceltesing{}
+			got, _, err := e.buildComparingQuery(tt.t, "company_cd", tt.req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("buildComparingQuery() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_exceltesing_Compare_WithoutPrimaryKey(t *testing.T) {
+	conn := testonly.OpenTestDB(t)
+	defer conn.Close()
+
+	testonly.ExecSQLFile(t, conn, filepath.Join("testdata", "schema", "ddl.sql"))
+
+	// Even if there is a difference in Compare(), t.Errorf() prevents the test from failing.
+	mockT := new(testing.T)
+
+	tests := []struct {
+		name    string
+		input   string
+		orderBy map[string][]string
+		equal   bool
+	}{
+		{
+			name:  "same rows in different order",
+			input: `INSERT INTO audit_log (event,company_cd) VALUES ('logout','00001'),('login','00001'),('login','00001');`,
+			equal: true,
+		},
+		{
+			name:  "duplicated row",
+			input: `INSERT INTO audit_log (event,company_cd) VALUES ('logout','00001'),('login','00001'),('login','00001'),('login','00001');`,
+			equal: false,
+		},
+		{
+			name:  "fewer duplicated rows",
+			input: `INSERT INTO audit_log (event,company_cd) VALUES ('logout','00001'),('login','00001');`,
+			equal: false,
+		},
+		{
+			name:    "order by",
+			input:   `INSERT INTO audit_log (event,company_cd) VALUES ('logout','00001'),('login','00001'),('login','00001');`,
+			orderBy: map[string][]string{"audit_log": {"event", "company_cd"}},
+			equal:   true,
+		},
+	}
+	for _, tt := range tests {
+		for _, mode := range []CompareMode{CompareModeTempTable, CompareModeReadOnly} {
+			t.Run(tt.name+"/"+string(mode), func(t *testing.T) {
+				if _, err := conn.Exec(`TRUNCATE audit_log;`); err != nil {
+					t.Fatal(err)
+				}
+				if _, err := conn.Exec(tt.input); err != nil {
+					t.Fatal(err)
+				}
+
+				e := New(conn)
+				got := e.Compare(mockT, CompareRequest{
+					TargetBookPath: filepath.Join("testdata", "compare_nopk.xlsx"),
+					OrderBy:        tt.orderBy,
+					Mode:           mode,
+				})
+				if got != tt.equal {
+					t.Errorf("Compare() should return %v but %v", tt.equal, got)
+				}
 			})
+		}
+	}
+}
 
+func Test_exceltesing_Compare_QuerySheet(t *testing.T) {
+	conn := testonly.OpenTestDB(t)
+	defer conn.Close()
+
+	testonly.ExecSQLFile(t, conn, filepath.Join("testdata", "schema", "ddl.sql"))
+
+	// Even if there is a difference in Compare(), t.Errorf() prevents the test from failing.
+	mockT := new(testing.T)
+
+	tests := []struct {
+		name       string
+		input      string
+		keyColumns map[string][]string
+		equal      bool
+	}{
+		{
+			name:  "equal",
+			input: `INSERT INTO audit_log (event,company_cd) VALUES ('login','00001'),('logout','00001');`,
+			equal: true,
+		},
+		{
+			name:       "equal with key columns",
+			input:      `INSERT INTO audit_log (event,company_cd) VALUES ('login','00001'),('logout','00001');`,
+			keyColumns: map[string][]string{"会社ごとのログ件数": {"company_cd"}},
+			equal:      true,
+		},
+		{
+			name:       "different count",
+			input:      `INSERT INTO audit_log (event,company_cd) VALUES ('login','00001'),('login','00002');`,
+			keyColumns: map[string][]string{"会社ごとのログ件数": {"company_cd"}},
+			equal:      false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := conn.Exec(`TRUNCATE company; TRUNCATE audit_log;`); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := conn.Exec(`INSERT INTO company (company_cd,company_name,founded_year,created_at,updated_at,revision) VALUES ('00001','Future',1989,current_timestamp,current_timestamp,1),('00002','YDC',1972,current_timestamp,current_timestamp,1);`); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := conn.Exec(tt.input); err != nil {
+				t.Fatal(err)
+			}
+
+			e := New(conn)
+			got := e.Compare(mockT, CompareRequest{
+				TargetBookPath: filepath.Join("testdata", "compare_query.xlsx"),
+				KeyColumns:     tt.keyColumns,
+			})
 			if got != tt.equal {
 				t.Errorf("Compare() should return %v but %v", tt.equal, got)
 			}
@@ -311,6 +792,146 @@ func Test_exceltesing_Compare(t *testing.T) {
 	}
 }
 
+func Test_exceltesing_Diff_SQLSheetPrefix(t *testing.T) {
+	conn := testonly.OpenTestDB(t)
+	defer conn.Close()
+
+	testonly.ExecSQLFile(t, conn, filepath.Join("testdata", "schema", "ddl.sql"))
+
+	// A2セルのマーカーを消し、シート名のプレフィックスのみでSQLシートとする
+	book := filepath.Join(t.TempDir(), "load_sql.xlsx")
+	f, err := excelize.OpenFile(filepath.Join("testdata", "load_sql.xlsx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.SetSheetName("後処理", "sql-後処理")
+	if err := f.SetCellValue("sql-後処理", "A2", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.SaveAs(book); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	e := New(conn)
+	if err := e.LoadWithContext(context.Background(), LoadRequest{TargetBookPath: book, SQLSheetPrefix: "sql-"}); err != nil {
+		t.Fatalf("LoadWithContext() error = %v", err)
+	}
+	res, err := e.Diff(context.Background(), CompareRequest{TargetBookPath: book, SQLSheetPrefix: "sql-"})
+	if err != nil {
+		t.Fatalf("Diff() error = %v", err)
+	}
+	if len(res.Tables) != 1 || res.Tables[0].Sheet != "会社" || res.Tables[0].Err != nil {
+		t.Errorf("Diff() should compare only the table sheet: %+v", res.Tables)
+	}
+}
+
+func Test_exceltesing_CompareTx(t *testing.T) {
+	conn := testonly.OpenTestDB(t)
+	defer conn.Close()
+
+	testonly.ExecSQLFile(t, conn, filepath.Join("testdata", "schema", "ddl.sql"))
+
+	if _, err := conn.Exec(`TRUNCATE company;`); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `INSERT INTO company (company_cd,company_name,founded_year,created_at,updated_at,revision)
+		VALUES ('00001','Future',1989,current_timestamp,current_timestamp,1),('00002','YDC',1972,current_timestamp,current_timestamp,1);`); err != nil {
+		t.Fatal(err)
+	}
+
+	e := New(conn)
+	r := CompareRequest{
+		TargetBookPath: filepath.Join("testdata", "compare.xlsx"),
+		SheetPrefix:    "会社",
+		IgnoreColumns:  []string{"created_at", "updated_at"},
+	}
+
+	// コミットされていない書き込みを比較できる
+	equal, errs := e.CompareTx(ctx, tx, r)
+	if !equal {
+		t.Errorf("CompareTx() should return true but false: %v", errs)
+	}
+
+	// 比較後も呼び出し元のトランザクションを継続できる
+	var count int
+	if err := tx.QueryRowContext(ctx, `SELECT count(*) FROM company;`).Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Errorf("company count = %d, want 2", count)
+	}
+
+	if err := tx.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+
+	// ロールバックした書き込みは別のトランザクションからは見えない
+	if equal, _ := e.CompareWithContext(ctx, r); equal {
+		t.Error("CompareWithContext() should return false after rollback")
+	}
+}
+
+func Test_exceltesing_CompareEventually(t *testing.T) {
+	conn := testonly.OpenTestDB(t)
+	defer conn.Close()
+
+	testonly.ExecSQLFile(t, conn, filepath.Join("testdata", "schema", "ddl.sql"))
+
+	e := New(conn)
+	r := CompareRequest{
+		TargetBookPath: filepath.Join("testdata", "compare.xlsx"),
+		SheetPrefix:    "会社",
+		IgnoreColumns:  []string{"created_at", "updated_at"},
+	}
+
+	t.Run("matched after async write", func(t *testing.T) {
+		if _, err := conn.Exec(`TRUNCATE company;`); err != nil {
+			t.Fatal(err)
+		}
+		done := make(chan error, 1)
+		go func() {
+			time.Sleep(200 * time.Millisecond)
+			_, err := conn.Exec(`INSERT INTO company (company_cd,company_name,founded_year,created_at,updated_at,revision)
+		VALUES ('00001','Future',1989,current_timestamp,current_timestamp,1),('00002','YDC',1972,current_timestamp,current_timestamp,1);`)
+			done <- err
+		}()
+
+		if !e.CompareEventually(t, r, 5*time.Second, 50*time.Millisecond) {
+			t.Error("CompareEventually() should return true but false")
+		}
+		if err := <-done; err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("report only last diff on timeout", func(t *testing.T) {
+		if _, err := conn.Exec(`TRUNCATE company;
+INSERT INTO company (company_cd,company_name,founded_year,created_at,updated_at,revision) VALUES ('00001','Future',1989,current_timestamp,current_timestamp,1);`); err != nil {
+			t.Fatal(err)
+		}
+
+		once := &fakeT{}
+		e.Compare(once, r)
+
+		ft := &fakeT{}
+		if e.CompareEventually(ft, r, 300*time.Millisecond, 50*time.Millisecond) {
+			t.Error("CompareEventually() should return false but true")
+		}
+		if diff := cmp.Diff(once.errors, ft.errors); diff != "" {
+			t.Errorf("CompareEventually() should report only the last diff (-want +got):\n%s", diff)
+		}
+	})
+}
+
 type testX struct {
 	ID string
 	A  bool
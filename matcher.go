@@ -0,0 +1,155 @@
+package exceltesting
+
+import (
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var (
+	uuidPattern       = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+	comparisonPattern = regexp.MustCompile(`^(>=|<=|>|<)\s*([+-]?\d+(?:\.\d+)?(?:[eE][+-]?\d+)?)$`)
+	tolerancePattern  = regexp.MustCompile(`^([+-]?\d+(?:\.\d+)?(?:[eE][+-]?\d+)?)\s*±\s*(\d+(?:\.\d+)?(?:[eE][+-]?\d+)?)$`)
+	nowPattern        = regexp.MustCompile(`^<now\s*±\s*([0-9a-zµ.]+)>$`)
+)
+
+// cellMatcher は期待値のセルに記載された、値を部分的に検証するマッチャーです。
+//
+//   - <any>: 任意の値
+//   - <null>, <notnull>: NULLである、NULLでない
+//   - ~^INV-\d{6}$: 正規表現に一致する
+//   - >=100, >100, <=100, <100: 数値の範囲
+//   - 1.5±0.01: 数値の許容誤差
+//   - <now±10s>: 比較時の現在時刻からの許容誤差
+//   - <uuid>: UUID
+//
+// 先頭の \ はエスケープで、\<any> のように記載するとマッチャーとして解釈せず、\ を除いた値と比較します。
+type cellMatcher struct {
+	src   string
+	match func(v x) bool
+}
+
+// matcherEscape はマッチャーとして解釈しないセルの先頭に記載するエスケープ文字です
+const matcherEscape = `\`
+
+// parseMatcher はセルの値をマッチャーとして解釈します。マッチャーでない場合は nil を返します
+func parseMatcher(cell string) (*cellMatcher, error) {
+	if strings.HasPrefix(cell, matcherEscape) {
+		return nil, nil
+	}
+	v := strings.Trim(strings.Trim(cell, "　"), " ")
+	m := &cellMatcher{src: v}
+
+	switch {
+	case strings.EqualFold(v, "<any>"):
+		m.match = func(x) bool { return true }
+	case strings.EqualFold(v, "<null>"):
+		m.match = func(v x) bool { return v.null }
+	case strings.EqualFold(v, "<notnull>"):
+		m.match = func(v x) bool { return !v.null }
+	case strings.EqualFold(v, "<uuid>"):
+		m.match = func(v x) bool { return !v.null && uuidPattern.MatchString(v.value) }
+	case strings.HasPrefix(v, "~"):
+		re, err := regexp.Compile(v[1:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid matcher %q: %w", v, err)
+		}
+		m.match = func(v x) bool { return !v.null && re.MatchString(v.value) }
+	case nowPattern.MatchString(v):
+		d, err := time.ParseDuration(nowPattern.FindStringSubmatch(v)[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid matcher %q: %w", v, err)
+		}
+		m.match = func(v x) bool {
+			if v.null {
+				return false
+			}
+			t, err := time.Parse(timeValueLayout, v.value)
+			if err != nil {
+				return false
+			}
+			diff := time.Since(t)
+			return -d <= diff && diff <= d
+		}
+	case comparisonPattern.MatchString(v):
+		s := comparisonPattern.FindStringSubmatch(v)
+		op := s[1]
+		want, ok := new(big.Rat).SetString(s[2])
+		if !ok {
+			return nil, nil
+		}
+		m.match = func(v x) bool {
+			got, ok := new(big.Rat).SetString(v.value)
+			if v.null || !ok {
+				return false
+			}
+			c := got.Cmp(want)
+			switch op {
+			case ">=":
+				return c >= 0
+			case ">":
+				return c > 0
+			case "<=":
+				return c <= 0
+			default:
+				return c < 0
+			}
+		}
+	case tolerancePattern.MatchString(v):
+		s := tolerancePattern.FindStringSubmatch(v)
+		want, ok1 := new(big.Rat).SetString(s[1])
+		tolerance, ok2 := new(big.Rat).SetString(s[2])
+		if !ok1 || !ok2 {
+			return nil, nil
+		}
+		m.match = func(v x) bool {
+			got, ok := new(big.Rat).SetString(v.value)
+			if v.null || !ok {
+				return false
+			}
+			diff := new(big.Rat).Sub(got, want)
+			return diff.Abs(diff).Cmp(tolerance) <= 0
+		}
+	default:
+		return nil, nil
+	}
+	return m, nil
+}
+
+// extractMatchers は t のマッチャーのセルをNULLに、エスケープしたセルを元の値に置き換えたコピーと、各セルのマッチャーを返します。
+// マッチャーでないセルは nil です
+func extractMatchers(t *table) (*table, [][]*cellMatcher, error) {
+	c := t.DeepCopy()
+	matchers := make([][]*cellMatcher, len(c.data))
+	for i, row := range c.data {
+		matchers[i] = make([]*cellMatcher, len(row))
+		for j, cell := range row {
+			m, err := parseMatcher(cell)
+			if err != nil {
+				return nil, nil, fmt.Errorf("row = %d, column = %s: %w", t.rowNum(i), t.columns[j], err)
+			}
+			if m == nil {
+				c.data[i][j] = unescapeMatcher(cell)
+				continue
+			}
+			matchers[i][j] = m
+			c.data[i][j] = ""
+		}
+	}
+	return &c, matchers, nil
+}
+
+// unescapeMatcher はエスケープしたセルの値から先頭の \ を除きます
+func unescapeMatcher(cell string) string {
+	return strings.TrimPrefix(cell, matcherEscape)
+}
+
+// escapeMatcher はマッチャーとして解釈される値、または \ で始まる値の先頭に \ を付与します
+func escapeMatcher(v string) string {
+	if m, _ := parseMatcher(v); m != nil || strings.HasPrefix(v, matcherEscape) {
+		return matcherEscape + v
+	}
+	return v
+}
@@ -0,0 +1,93 @@
+package exceltesting
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_parseMatcher(t *testing.T) {
+	now := time.Now().UTC().Format(timeValueLayout)
+	hourAgo := time.Now().Add(-time.Hour).UTC().Format(timeValueLayout)
+
+	tests := []struct {
+		name    string
+		cell    string
+		v       x
+		want    bool
+		wantNil bool
+		wantErr bool
+	}{
+		{name: "not a matcher", cell: "Future", wantNil: true},
+		{name: "any", cell: "<any>", v: x{null: true}, want: true},
+		{name: "null", cell: "<null>", v: x{null: true}, want: true},
+		{name: "null with value", cell: "<NULL>", v: x{value: "1"}, want: false},
+		{name: "notnull", cell: "<notnull>", v: x{value: ""}, want: true},
+		{name: "notnull with null", cell: "<notnull>", v: x{null: true}, want: false},
+		{name: "regex", cell: `~^INV-\d{6}$`, v: x{value: "INV-000123"}, want: true},
+		{name: "regex unmatched", cell: `~^INV-\d{6}$`, v: x{value: "INV-123"}, want: false},
+		{name: "invalid regex", cell: `~(`, wantErr: true},
+		{name: "greater or equal", cell: ">=100", v: x{value: "100"}, want: true},
+		{name: "greater", cell: "> 100", v: x{value: "100"}, want: false},
+		{name: "less", cell: "<100", v: x{value: "99.9"}, want: true},
+		{name: "less or equal with text", cell: "<=100", v: x{value: "abc"}, want: false},
+		{name: "tolerance", cell: "1.5±0.01", v: x{value: "1.509"}, want: true},
+		{name: "tolerance exceeded", cell: "1.5 ± 0.01", v: x{value: "1.52"}, want: false},
+		{name: "now", cell: "<now±10s>", v: x{value: now}, want: true},
+		{name: "now exceeded", cell: "<now±10s>", v: x{value: hourAgo}, want: false},
+		{name: "invalid duration", cell: "<now±10x>", wantErr: true},
+		{name: "version is not a comparison", cell: ">1.2.3", wantNil: true},
+		{name: "dot is not a comparison", cell: "<.", wantNil: true},
+		{name: "version is not a tolerance", cell: "1.2.3±0.1", wantNil: true},
+		{name: "escaped", cell: `\<any>`, wantNil: true},
+		{name: "uuid", cell: "<uuid>", v: x{value: "7c9e6679-7425-40de-944b-e07fc1f90ae7"}, want: true},
+		{name: "not uuid", cell: "<uuid>", v: x{value: "7c9e6679"}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := parseMatcher(tt.cell)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseMatcher() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if (m == nil) != tt.wantNil {
+				t.Fatalf("parseMatcher() = %v, wantNil %v", m, tt.wantNil)
+			}
+			if m == nil {
+				return
+			}
+			if got := m.match(tt.v); got != tt.want {
+				t.Errorf("match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_escapeMatcher(t *testing.T) {
+	tests := []struct {
+		name string
+		v    string
+		want string
+	}{
+		{name: "literal", v: "Future", want: "Future"},
+		{name: "matcher", v: "<any>", want: `\<any>`},
+		{name: "regex", v: "~abc", want: `\~abc`},
+		{name: "backslash", v: `\abc`, want: `\\abc`},
+		{name: "version", v: ">1.2.3", want: ">1.2.3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := escapeMatcher(tt.v)
+			if got != tt.want {
+				t.Errorf("escapeMatcher() = %v, want %v", got, tt.want)
+			}
+			if m, _ := parseMatcher(got); m != nil {
+				t.Errorf("parseMatcher(%q) = %v, want nil", got, m)
+			}
+			if u := unescapeMatcher(got); u != tt.v {
+				t.Errorf("unescapeMatcher() = %v, want %v", u, tt.v)
+			}
+		})
+	}
+}
@@ -0,0 +1,195 @@
+package exceltesting
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgtype"
+)
+
+// valueKind は比較時の正規化方法を表すカラムの型の分類です
+type valueKind int
+
+const (
+	kindText valueKind = iota
+	kindNumeric
+	kindTime
+	kindDate
+	kindJSON
+	kindBool
+	kindBytes
+)
+
+// kindOfDBType は database/sql の ColumnType.DatabaseTypeName() 、
+// または information_schema.columns の data_type から正規化方法を判定します
+func kindOfDBType(dbType string) valueKind {
+	switch strings.ToUpper(dbType) {
+	case "NUMERIC", "DECIMAL", "INT2", "INT4", "INT8", "FLOAT4", "FLOAT8",
+		"TINYINT", "SMALLINT", "MEDIUMINT", "INT", "INTEGER", "BIGINT", "FLOAT", "DOUBLE", "REAL", "DOUBLE PRECISION",
+		"UNSIGNED TINYINT", "UNSIGNED SMALLINT", "UNSIGNED MEDIUMINT", "UNSIGNED INT", "UNSIGNED BIGINT":
+		return kindNumeric
+	case "TIMESTAMP", "TIMESTAMPTZ", "DATETIME", "TIMESTAMP WITH TIME ZONE", "TIMESTAMP WITHOUT TIME ZONE":
+		return kindTime
+	case "DATE":
+		return kindDate
+	case "JSON", "JSONB":
+		return kindJSON
+	case "BOOL", "BOOLEAN":
+		return kindBool
+	case "BYTEA", "BLOB", "TINYBLOB", "MEDIUMBLOB", "LONGBLOB", "BINARY", "VARBINARY":
+		return kindBytes
+	default:
+		return kindText
+	}
+}
+
+// timeValueLayout は正規化した日時の形式です
+const timeValueLayout = "2006-01-02 15:04:05.999999999Z07:00"
+
+// normalizeOption は値の正規化の設定です
+type normalizeOption struct {
+	// timePrecision は日時を比較する精度です。0の場合は切り捨てません
+	timePrecision time.Duration
+}
+
+// normalizeValue はデータベースから取得した値を、カラムの型に応じて比較可能な文字列に正規化します。
+//
+//   - 数値は10進数の値として正規化し、1.0 と 1 を同一とみなします
+//   - 日時はUTCの時刻として正規化し、指定された精度で切り捨てます
+//   - JSONはキーの順序や空白を無視して正規化します
+//   - 真偽値は true / false に正規化します
+//   - バイト列は16進数に正規化します
+func normalizeValue(v any, kind valueKind, opt normalizeOption) string {
+	if v == nil {
+		return ""
+	}
+
+	switch kind {
+	case kindNumeric:
+		return normalizeNumeric(valueString(v))
+	case kindTime, kindDate:
+		t, ok := v.(time.Time)
+		if !ok {
+			return valueString(v)
+		}
+		if kind == kindDate {
+			return t.Format("2006-01-02")
+		}
+		if opt.timePrecision > 0 {
+			t = t.Truncate(opt.timePrecision)
+		}
+		return t.UTC().Format(timeValueLayout)
+	case kindJSON:
+		return normalizeJSON(valueString(v))
+	case kindBool:
+		return normalizeBool(v)
+	case kindBytes:
+		if b, ok := v.([]byte); ok {
+			return `\x` + hex.EncodeToString(b)
+		}
+		return valueString(v)
+	default:
+		return valueString(v)
+	}
+}
+
+// valueString は値をそのまま文字列に変換します
+func valueString(v any) string {
+	switch t := v.(type) {
+	case []byte:
+		return string(t)
+	case string:
+		return t
+	case pgtype.Numeric:
+		var s string
+		if err := t.AssignTo(&s); err != nil {
+			return fmt.Sprint(t)
+		}
+		return s
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(t), 'f', -1, 32)
+	default:
+		return fmt.Sprint(t)
+	}
+}
+
+// normalizeNumeric は数値の文字列を末尾の0を除いた10進数表現に正規化します。数値でない場合はそのまま返します。
+func normalizeNumeric(s string) string {
+	r, ok := new(big.Rat).SetString(strings.TrimSpace(s))
+	if !ok {
+		return s
+	}
+
+	// 有限の10進数で表現できる桁数を求める
+	ten := big.NewRat(10, 1)
+	scaled := new(big.Rat).Set(r)
+	digits := 0
+	for !scaled.IsInt() && digits < 1000 {
+		scaled.Mul(scaled, ten)
+		digits++
+	}
+	return r.FloatString(digits)
+}
+
+// normalizeJSON はJSONをキーの順序や空白に依存しない表現に正規化します。JSONでない場合はそのまま返します。
+func normalizeJSON(s string) string {
+	d := json.NewDecoder(strings.NewReader(s))
+	d.UseNumber()
+
+	var v any
+	if err := d.Decode(&v); err != nil {
+		return s
+	}
+
+	var b bytes.Buffer
+	e := json.NewEncoder(&b)
+	e.SetEscapeHTML(false)
+	if err := e.Encode(normalizeJSONNumber(v)); err != nil {
+		return s
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// normalizeJSONNumber はJSONに含まれる数値を正規化します。キーの順序は encoding/json によってソートされます
+func normalizeJSONNumber(v any) any {
+	switch t := v.(type) {
+	case json.Number:
+		return json.Number(normalizeNumeric(t.String()))
+	case map[string]any:
+		for k, e := range t {
+			t[k] = normalizeJSONNumber(e)
+		}
+	case []any:
+		for i, e := range t {
+			t[i] = normalizeJSONNumber(e)
+		}
+	}
+	return v
+}
+
+func normalizeBool(v any) string {
+	switch t := v.(type) {
+	case bool:
+		return strconv.FormatBool(t)
+	case int64:
+		return strconv.FormatBool(t != 0)
+	}
+
+	s := strings.ToLower(strings.TrimSpace(valueString(v)))
+	switch s {
+	case "t", "true", "y", "yes", "on", "1":
+		return "true"
+	case "f", "false", "n", "no", "off", "0":
+		return "false"
+	default:
+		return s
+	}
+}
@@ -0,0 +1,148 @@
+package exceltesting
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_normalizeValue(t *testing.T) {
+	jst := time.FixedZone("Asia/Tokyo", 9*60*60)
+
+	tests := []struct {
+		name string
+		v    any
+		kind valueKind
+		opt  normalizeOption
+		want string
+	}{
+		{
+			name: "nil",
+			v:    nil,
+			kind: kindNumeric,
+			want: "",
+		},
+		{
+			name: "numeric trailing zeros",
+			v:    "1.0",
+			kind: kindNumeric,
+			want: "1",
+		},
+		{
+			name: "numeric decimal",
+			v:    "123.4500",
+			kind: kindNumeric,
+			want: "123.45",
+		},
+		{
+			name: "numeric exponent",
+			v:    "1.23E+2",
+			kind: kindNumeric,
+			want: "123",
+		},
+		{
+			name: "integer",
+			v:    int64(42),
+			kind: kindNumeric,
+			want: "42",
+		},
+		{
+			name: "float",
+			v:    float64(0.1),
+			kind: kindNumeric,
+			want: "0.1",
+		},
+		{
+			name: "timestamp with zone",
+			v:    time.Date(2022, 4, 1, 9, 0, 0, 123456789, jst),
+			kind: kindTime,
+			want: "2022-04-01 00:00:00.123456789Z",
+		},
+		{
+			name: "timestamp with precision",
+			v:    time.Date(2022, 4, 1, 9, 0, 0, 123456789, jst),
+			kind: kindTime,
+			opt:  normalizeOption{timePrecision: time.Millisecond},
+			want: "2022-04-01 00:00:00.123Z",
+		},
+		{
+			name: "date",
+			v:    time.Date(2022, 4, 1, 0, 0, 0, 0, time.UTC),
+			kind: kindDate,
+			want: "2022-04-01",
+		},
+		{
+			name: "json key order",
+			v:    `{"b": 1, "a": [true, null, 1.50]}`,
+			kind: kindJSON,
+			want: `{"a":[true,null,1.5],"b":1}`,
+		},
+		{
+			name: "invalid json",
+			v:    `{"a":`,
+			kind: kindJSON,
+			want: `{"a":`,
+		},
+		{
+			name: "bool",
+			v:    true,
+			kind: kindBool,
+			want: "true",
+		},
+		{
+			name: "bool from text",
+			v:    "f",
+			kind: kindBool,
+			want: "false",
+		},
+		{
+			name: "bool from tinyint",
+			v:    int64(1),
+			kind: kindBool,
+			want: "true",
+		},
+		{
+			name: "bytes",
+			v:    []byte{0xde, 0xad, 0xbe, 0xef},
+			kind: kindBytes,
+			want: `\xdeadbeef`,
+		},
+		{
+			name: "text",
+			v:    []byte("フューチャー"),
+			kind: kindText,
+			want: "フューチャー",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := normalizeValue(tt.v, tt.kind, tt.opt); got != tt.want {
+				t.Errorf("normalizeValue() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_kindOfDBType(t *testing.T) {
+	tests := []struct {
+		dbType string
+		want   valueKind
+	}{
+		{dbType: "NUMERIC", want: kindNumeric},
+		{dbType: "INT8", want: kindNumeric},
+		{dbType: "DECIMAL", want: kindNumeric},
+		{dbType: "TIMESTAMPTZ", want: kindTime},
+		{dbType: "DATETIME", want: kindTime},
+		{dbType: "DATE", want: kindDate},
+		{dbType: "JSONB", want: kindJSON},
+		{dbType: "BOOL", want: kindBool},
+		{dbType: "BYTEA", want: kindBytes},
+		{dbType: "VARCHAR", want: kindText},
+	}
+	for _, tt := range tests {
+		t.Run(tt.dbType, func(t *testing.T) {
+			if got := kindOfDBType(tt.dbType); got != tt.want {
+				t.Errorf("kindOfDBType() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
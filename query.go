@@ -1,32 +1,61 @@
 package exceltesting
 
 const (
+	// getPrimaryKeyQuery は主キー、または一意インデックスのカラムを返します。
+	// 自身にインデックスがない継承テーブルやパーティションは、親テーブルのインデックスを利用します。
 	getPrimaryKeyQuery = `
+WITH RECURSIVE ancestors(relid, depth) AS (
+	SELECT
+		T.oid
+	,	0
+	FROM
+		pg_class		AS	T
+	,	pg_namespace	AS	N
+	WHERE
+		N.oid			=	T.relnamespace
+	AND	T.relkind		IN	('r', 'p', 'f')
+	AND	N.nspname		=	CURRENT_SCHEMA()
+	AND	T.relname		=	$1
+UNION ALL
+	SELECT
+		inh.inhparent
+	,	a.depth + 1
+	FROM
+		pg_inherits		AS	inh
+	,	ancestors		AS	a
+	WHERE
+		inh.inhrelid	=	a.relid
+)
 SELECT
-	array_to_string(ARRAY_AGG(A.attname), ',')	AS	column_names
+	array_to_string(ARRAY(
+		SELECT
+			A.attname
+		FROM
+			unnest(ix.indkey::int2[]) WITH ORDINALITY AS k(attnum, ord)
+		,	pg_attribute	AS	A
+		WHERE
+			A.attrelid		=	ix.indrelid
+		AND	A.attnum		=	k.attnum
+		AND	k.ord			<=	ix.indnkeyatts
+		ORDER BY
+			k.ord
+	), ',')	AS	column_names
 FROM
-	pg_class		AS	T
-,	pg_class		AS	i
+	ancestors		AS	a
 ,	pg_index		AS	ix
-,	pg_attribute	AS	A
-,	pg_tables		AS	ta
+,	pg_class		AS	i
 WHERE
-	T.oid			=	ix.indrelid
+	ix.indrelid		=	a.relid
 AND	i.oid			=	ix.indexrelid
-AND	ix.indisprimary	=	TRUE
-AND	A.attrelid		=	T.oid
-AND	A.attnum		=	ANY(ix.indkey)
-AND	T.relkind		IN	('r', 'p') -- TODO: 将来的には他の relkind にも対応する予定
-AND	T.relname		=	ta.tablename
-AND	ta.schemaname	=	CURRENT_SCHEMA()
-AND	T.relname		=	$1
-GROUP BY
-	T.relname
-,	i.relname
-,	ix.indisprimary
+AND	ix.indisunique	=	TRUE
+AND	ix.indexprs		IS	NULL
+AND	ix.indpred		IS	NULL
 ORDER BY
-	T.relname
+	a.depth
+,	ix.indisprimary	DESC
+,	ix.indnkeyatts
 ,	i.relname
+LIMIT 1
 ;`
 
 	getTableNotNullColumns = `
@@ -48,4 +77,138 @@ ORDER BY
 	ordinal_position
 ;
 `
+
+	// getTableColumnTypes はテーブルのカラム名と型を返します
+	getTableColumnTypes = `
+SELECT
+	column_name
+,	data_type
+FROM
+	information_schema.columns
+WHERE
+	table_schema	=	CURRENT_SCHEMA()
+AND	table_name		=	$1
+ORDER BY
+	ordinal_position
+;
+`
+
+	// getTableColumnTypesMySQL はMySQLでテーブルのカラム名と型を返します
+	getTableColumnTypesMySQL = `
+SELECT
+  column_name,
+  data_type
+FROM information_schema.columns
+WHERE table_schema = DATABASE()
+  AND table_name = ?
+ORDER BY ordinal_position;`
+
+	// getDumpTableDefs はテーブルとカラムの定義をテーブル名、カラムの定義順に返します。
+	// 外部テーブルを含み、パーティションは $1 が true の場合のみ含みます。
+	getDumpTableDefs = `
+SELECT
+	tab.relname				AS	table_name
+,	tabdesc.description		AS	table_description
+,	col.column_name
+,	coldesc.description		AS	column_description
+,	col.data_type
+FROM
+	pg_class	AS	tab
+	INNER JOIN pg_namespace	AS	ns
+		ON	tab.relnamespace	=	ns.oid
+	LEFT OUTER JOIN pg_description	AS	tabdesc
+		ON	tab.oid				=	tabdesc.objoid
+		AND	tabdesc.objsubid	=	'0'
+	LEFT OUTER JOIN information_schema.columns	AS	col
+		ON	tab.relname			=	col.table_name
+		AND	ns.nspname			=	col.table_schema
+	LEFT OUTER JOIN pg_description	AS	coldesc
+		ON	tab.oid				=	coldesc.objoid
+		AND	col.ordinal_position	=	coldesc.objsubid
+WHERE
+	ns.nspname	=	CURRENT_SCHEMA()
+AND	tab.relkind	IN	('r', 'p', 'f')
+AND	(tab.relispartition = FALSE OR $1)
+ORDER BY
+	tab.relname
+,	col.ordinal_position
+;
+`
+
+	// getDumpTableDefsMySQL はMySQLでテーブルとカラムの定義をテーブル名、カラムの定義順に返します
+	getDumpTableDefsMySQL = `
+SELECT
+  t.table_name,
+  t.table_comment,
+  c.column_name,
+  c.column_comment,
+  c.data_type
+FROM information_schema.tables t
+LEFT OUTER JOIN information_schema.columns c
+  ON c.table_schema = t.table_schema
+  AND c.table_name = t.table_name
+WHERE t.table_schema = DATABASE()
+  AND t.table_type = 'BASE TABLE'
+ORDER BY t.table_name, c.ordinal_position;`
+
+	// getForeignKeys は外部キーの制約名、参照元テーブル、参照先テーブル、参照元カラム、参照先カラムを返します
+	getForeignKeys = `
+SELECT
+	con.conname
+,	child.relname
+,	parent.relname
+,	array_to_string(ARRAY(
+		SELECT
+			A.attname
+		FROM
+			unnest(con.conkey::int2[]) WITH ORDINALITY AS k(attnum, ord)
+		,	pg_attribute	AS	A
+		WHERE
+			A.attrelid	=	con.conrelid
+		AND	A.attnum	=	k.attnum
+		ORDER BY
+			k.ord
+	), ',')	AS	column_names
+,	array_to_string(ARRAY(
+		SELECT
+			A.attname
+		FROM
+			unnest(con.confkey::int2[]) WITH ORDINALITY AS k(attnum, ord)
+		,	pg_attribute	AS	A
+		WHERE
+			A.attrelid	=	con.confrelid
+		AND	A.attnum	=	k.attnum
+		ORDER BY
+			k.ord
+	), ',')	AS	ref_column_names
+FROM
+	pg_constraint	AS	con
+,	pg_class		AS	child
+,	pg_class		AS	parent
+,	pg_namespace	AS	N
+WHERE
+	con.contype		=	'f'
+AND	child.oid		=	con.conrelid
+AND	parent.oid		=	con.confrelid
+AND	N.oid			=	child.relnamespace
+AND	N.nspname		=	CURRENT_SCHEMA()
+ORDER BY
+	child.relname
+,	con.conname
+;
+`
+
+	// getForeignKeysMySQL はMySQLで外部キーの制約名、参照元テーブル、参照先テーブル、参照元カラム、参照先カラムを返します
+	getForeignKeysMySQL = `
+SELECT
+  k.constraint_name,
+  k.table_name,
+  k.referenced_table_name,
+  GROUP_CONCAT(k.column_name ORDER BY k.ordinal_position SEPARATOR ','),
+  GROUP_CONCAT(k.referenced_column_name ORDER BY k.ordinal_position SEPARATOR ',')
+FROM information_schema.KEY_COLUMN_USAGE k
+WHERE k.table_schema = DATABASE()
+  AND k.referenced_table_name IS NOT NULL
+GROUP BY k.constraint_name, k.table_name, k.referenced_table_name
+ORDER BY k.table_name, k.constraint_name;`
 )
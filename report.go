@@ -0,0 +1,274 @@
+package exceltesting
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+const (
+	reportAuthor = "exceltesting"
+
+	// 差分の種類ごとのセルの背景色
+	reportChangedColor = "#FFC7CE"
+	reportMissingColor = "#FFEB9C"
+	reportExtraColor   = "#BDD7EE"
+)
+
+// writeReport は期待値のBookをコピーし、差分のあるセルや行を強調したレポートを path に保存します。
+//
+//   - 値が異なるセルは背景色を付け、実際の値をコメントに記載します
+//   - 期待値にのみ存在する行は背景色を付け、A列のコメントに記載します
+//   - データベースにのみ存在する行は、データ行の末尾に別の背景色で追加します
+func writeReport(path, book string, res *CompareResult) error {
+	f, err := excelize.OpenFile(book)
+	if err != nil {
+		return fmt.Errorf("open excel file: %w", err)
+	}
+	defer f.Close()
+
+	if err := annotate(f, res); err != nil {
+		return err
+	}
+	if err := f.SaveAs(path); err != nil {
+		return fmt.Errorf("save report: %w", err)
+	}
+	return nil
+}
+
+// annotate はBookの各シートに、比較結果 res の差分の背景色とコメントを書き込みます
+func annotate(f *excelize.File, res *CompareResult) error {
+	changed, err := newFillStyles(f, reportChangedColor)
+	if err != nil {
+		return err
+	}
+	missing, err := newFillStyles(f, reportMissingColor)
+	if err != nil {
+		return err
+	}
+	extra, err := newFillStyles(f, reportExtraColor)
+	if err != nil {
+		return err
+	}
+
+	for _, d := range res.Tables {
+		if d.Equal() || d.Err != nil {
+			continue
+		}
+
+		l, err := newSheetLayout(f, d.Sheet)
+		if err != nil {
+			return fmt.Errorf("sheet = %s: %w", d.Sheet, err)
+		}
+
+		for _, c := range d.ChangedCells {
+			cell, ok := l.cell(c.Row, c.Column)
+			if !ok {
+				continue
+			}
+			if err := changed.apply(d.Sheet, cell); err != nil {
+				return err
+			}
+			if err := addComment(f, d.Sheet, cell, "got: "+displayValue(c.Got)); err != nil {
+				return err
+			}
+		}
+
+		for _, r := range d.MissingRows {
+			line, ok := l.lines[r.Row]
+			if !ok {
+				continue
+			}
+			for col := 1; col <= l.lastColumn; col++ {
+				cell, _ := excelize.CoordinatesToCellName(col, line)
+				if err := missing.apply(d.Sheet, cell); err != nil {
+					return err
+				}
+			}
+			first, _ := excelize.CoordinatesToCellName(1, line)
+			if err := addComment(f, d.Sheet, first, "missing in database"); err != nil {
+				return err
+			}
+		}
+
+		// 追加する行は最後のデータ行の書式を引き継ぐ
+		bases := make([]int, l.lastColumn+1)
+		if last := l.lastDataLine(); last > 0 {
+			for col := 1; col <= l.lastColumn; col++ {
+				cell, _ := excelize.CoordinatesToCellName(col, last)
+				bases[col], _ = f.GetCellStyle(d.Sheet, cell)
+			}
+		}
+		for i, r := range d.ExtraRows {
+			line := l.lastLine + 1 + i
+			first, _ := excelize.CoordinatesToCellName(1, line)
+			_ = f.SetCellValue(d.Sheet, first, "+")
+			for _, c := range r.Cells {
+				col, ok := l.columns[c.Column]
+				if !ok {
+					continue
+				}
+				cell, _ := excelize.CoordinatesToCellName(col, line)
+				_ = f.SetCellValue(d.Sheet, cell, c.Value)
+			}
+			for col := 1; col <= l.lastColumn; col++ {
+				cell, _ := excelize.CoordinatesToCellName(col, line)
+				if err := extra.applyFrom(d.Sheet, cell, bases[col]); err != nil {
+					return err
+				}
+			}
+			if err := addComment(f, d.Sheet, first, "unexpected row in database"); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// fillStyles はセルの既存の書式から背景色のみを変更した書式を作成します。
+// 数値の書式やフォント、罫線、配置は元の書式のまま残します。
+type fillStyles struct {
+	f      *excelize.File
+	fillID int
+	// styles は元の書式ごとの、背景色を変更した書式です
+	styles map[int]int
+}
+
+func newFillStyles(f *excelize.File, color string) (*fillStyles, error) {
+	// 背景色のみの書式を作成し、その塗りつぶしを既存の書式に適用する
+	id, err := f.NewStyle(&excelize.Style{Fill: excelize.Fill{Type: "pattern", Color: []string{color}, Pattern: 1}})
+	if err != nil {
+		return nil, fmt.Errorf("new style: %w", err)
+	}
+	xf := f.Styles.CellXfs.Xf[id]
+	if xf.FillID == nil {
+		return nil, fmt.Errorf("new style: fill of %s is not created", color)
+	}
+	return &fillStyles{f: f, fillID: *xf.FillID, styles: make(map[int]int)}, nil
+}
+
+// apply はセルの書式を、背景色のみを変更した書式にします
+func (s *fillStyles) apply(sheet, cell string) error {
+	base, err := s.f.GetCellStyle(sheet, cell)
+	if err != nil {
+		return fmt.Errorf("get style %s!%s: %w", sheet, cell, err)
+	}
+	return s.applyFrom(sheet, cell, base)
+}
+
+// applyFrom はセルの書式を、書式 base の背景色のみを変更した書式にします
+func (s *fillStyles) applyFrom(sheet, cell string, base int) error {
+	id, ok := s.styles[base]
+	if !ok {
+		// excelize v2.6.0 は既存の書式を取得できないため、書式の定義を直接複製する
+		xfs := s.f.Styles.CellXfs
+		if base < 0 || len(xfs.Xf) <= base {
+			base = 0
+		}
+		xf := xfs.Xf[base]
+		fillID, applyFill := s.fillID, true
+		xf.FillID, xf.ApplyFill = &fillID, &applyFill
+		xfs.Xf = append(xfs.Xf, xf)
+		xfs.Count = len(xfs.Xf)
+		id = len(xfs.Xf) - 1
+		s.styles[base] = id
+	}
+	if err := s.f.SetCellStyle(sheet, cell, cell, id); err != nil {
+		return fmt.Errorf("set style %s!%s: %w", sheet, cell, err)
+	}
+	return nil
+}
+
+// sheetLayout はシートのカラムとA列の番号の、Excel上の位置です
+type sheetLayout struct {
+	// columns はカラム名ごとの列番号です
+	columns map[string]int
+	// lines はA列の番号ごとの行番号です
+	lines map[int]int
+	// lastColumn はカラム定義の最後の列番号です
+	lastColumn int
+	// lastLine はシートの最後の行番号です
+	lastLine int
+}
+
+func newSheetLayout(f *excelize.File, sheet string) (*sheetLayout, error) {
+	columnDefineRowNum := 9
+	if extractSheetFormatVersion(f, sheet) == "2.0" {
+		columnDefineRowNum = 6
+	}
+
+	rows, err := f.GetRows(sheet)
+	if err != nil {
+		return nil, fmt.Errorf("get rows: %w", err)
+	}
+	if len(rows) < columnDefineRowNum {
+		return nil, fmt.Errorf("column definition row not found")
+	}
+
+	l := &sheetLayout{
+		columns:  make(map[string]int),
+		lines:    make(map[int]int),
+		lastLine: len(rows),
+	}
+	for j, cell := range rows[columnDefineRowNum-1] {
+		cell = strings.Trim(strings.Trim(cell, "　"), " ")
+		if j == 0 || cell == "" {
+			continue
+		}
+		l.columns[cell] = j + 1
+		l.lastColumn = j + 1
+	}
+
+	// getExcelData と同じ規則でA列の番号を求める
+	for i, row := range rows[columnDefineRowNum:] {
+		if len(row) == 0 || row[0] == "" {
+			continue
+		}
+		line := columnDefineRowNum + i + 1
+		no, err := strconv.Atoi(strings.TrimSpace(row[0]))
+		if err != nil {
+			no = line
+		}
+		l.lines[no] = line
+	}
+	return l, nil
+}
+
+// lastDataLine は最後のデータ行の行番号を返します。データ行がない場合は0です
+func (l *sheetLayout) lastDataLine() int {
+	last := 0
+	for _, line := range l.lines {
+		if line > last {
+			last = line
+		}
+	}
+	return last
+}
+
+// cell はA列の番号 row とカラム名 column のセルの位置を返します
+func (l *sheetLayout) cell(row int, column string) (string, bool) {
+	line, ok := l.lines[row]
+	if !ok {
+		return "", false
+	}
+	col, ok := l.columns[column]
+	if !ok {
+		return "", false
+	}
+	cell, err := excelize.CoordinatesToCellName(col, line)
+	return cell, err == nil
+}
+
+func addComment(f *excelize.File, sheet, cell, text string) error {
+	b, err := json.Marshal(map[string]string{"author": reportAuthor + ": ", "text": text})
+	if err != nil {
+		return err
+	}
+	if err := f.AddComment(sheet, cell, string(b)); err != nil {
+		return fmt.Errorf("add comment %s!%s: %w", sheet, cell, err)
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package exceltesting
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/xuri/excelize/v2"
+)
+
+func Test_writeReport(t *testing.T) {
+	res := &CompareResult{
+		Tables: []TableDiff{
+			{
+				Table: "company",
+				Sheet: "会社",
+				MissingRows: []Row{
+					{Row: 2, Cells: []Cell{{Column: "company_cd", Value: "00002"}}},
+				},
+				ExtraRows: []Row{
+					{Cells: []Cell{{Column: "company_cd", Value: "00003"}, {Column: "company_name", Value: "FutureOne"}}},
+				},
+				ChangedCells: []CellDiff{
+					{Row: 1, Column: "founded_year", Want: "1989", Got: "9891"},
+				},
+			},
+			{
+				Table: "temperature",
+				Sheet: "気温",
+			},
+		},
+	}
+
+	path := filepath.Join(t.TempDir(), "report.xlsx")
+	if err := writeReport(path, filepath.Join("testdata", "compare.xlsx"), res); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := excelize.OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var comments []string
+	for _, c := range f.GetComments()["会社"] {
+		comments = append(comments, c.Ref+": "+c.Text)
+	}
+	wantComments := []string{
+		"D10: exceltesting: got: 9891",
+		"A11: exceltesting: missing in database",
+		"A12: exceltesting: unexpected row in database",
+	}
+	if diff := cmp.Diff(wantComments, comments); diff != "" {
+		t.Errorf("comments mismatch (-want +got):\n%s", diff)
+	}
+
+	rows, err := f.GetRows("会社")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"+", "00003", "FutureOne"}, rows[11]); diff != "" {
+		t.Errorf("extra row mismatch (-want +got):\n%s", diff)
+	}
+
+	// 差分のあるセルや行にはスタイルを設定する
+	for _, cell := range []string{"D10", "B11", "G11", "C12"} {
+		if s, _ := f.GetCellStyle("会社", cell); s == 0 {
+			t.Errorf("style of %s is not set", cell)
+		}
+	}
+	if s1, s2 := getCellStyle(t, f, "C10"), getCellStyle(t, f, "D10"); s1 == s2 {
+		t.Errorf("style of changed cell D10 should differ from C10")
+	}
+
+	// 背景色のみを変更し、数値の書式やフォント、罫線、配置はデータ行の書式のまま残す
+	want := f.Styles.CellXfs.Xf[getCellStyle(t, f, "C10")]
+	for _, cell := range []string{"D10", "B11", "C12"} {
+		got := f.Styles.CellXfs.Xf[getCellStyle(t, f, cell)]
+		if got.FillID == nil || *got.FillID == 0 {
+			t.Errorf("fill of %s is not set", cell)
+		}
+		got.FillID, got.ApplyFill = want.FillID, want.ApplyFill
+		if diff := cmp.Diff(want, got, cmp.Exporter(func(reflect.Type) bool { return true })); diff != "" {
+			t.Errorf("style of %s mismatch (-want +got):\n%s", cell, diff)
+		}
+	}
+}
+
+func getCellStyle(t *testing.T, f *excelize.File, cell string) int {
+	t.Helper()
+	s, err := f.GetCellStyle("会社", cell)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
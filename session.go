@@ -0,0 +1,155 @@
+package exceltesting
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// settingNamePattern はセッション設定名として許可する形式です (e.g. TimeZone, app.current_tenant)
+var settingNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.]*$`)
+
+// applySessionSettings はトランザクション内でセッション設定を適用します。
+//
+// PostgreSQLの場合は SET LOCAL と同様にトランザクション終了時に元に戻ります。
+// MySQLは SET LOCAL をサポートしていないため、変更前の値を取得してから SET SESSION で適用します。
+// 返却する restore はトランザクションの終了前に呼び出し、MySQLの接続のセッション設定を元に戻します。
+// restore はエラーの場合も返却し、何度呼び出しても元に戻すのは1回のみです。
+func applySessionSettings(ctx context.Context, tx *sql.Tx, d Dialect, settings map[string]string) (restore func() error, err error) {
+	var previous []sessionSetting
+	restored := false
+	restore = func() error {
+		if restored {
+			return nil
+		}
+		restored = true
+		// ctx がキャンセルされていても元に戻せるようにする
+		for i := len(previous) - 1; i >= 0; i-- {
+			if err := previous[i].restore(context.Background(), tx); err != nil {
+				return fmt.Errorf("restore %s: %w", previous[i].name, err)
+			}
+		}
+		return nil
+	}
+
+	for _, name := range sortedSettingNames(settings) {
+		if err := validateSettingName(name); err != nil {
+			return restore, err
+		}
+
+		switch d {
+		case DialectMySQL:
+			p := sessionSetting{name: name}
+			if err := tx.QueryRowContext(ctx, "SELECT @@SESSION."+name).Scan(&p.value); err != nil {
+				return restore, fmt.Errorf("get %s: %w", name, err)
+			}
+			if _, err := tx.ExecContext(ctx, fmt.Sprintf("SET SESSION %s = ?", name), settings[name]); err != nil {
+				return restore, fmt.Errorf("set %s: %w", name, err)
+			}
+			previous = append(previous, p)
+		default:
+			// set_config の第3引数 true は SET LOCAL と同じ
+			if _, err := tx.ExecContext(ctx, "SELECT set_config($1, $2, true)", name, settings[name]); err != nil {
+				return restore, fmt.Errorf("set %s: %w", name, err)
+			}
+		}
+	}
+	return restore, nil
+}
+
+// sessionSetting はMySQLで変更する前のセッション設定の値です
+type sessionSetting struct {
+	name  string
+	value sql.NullString
+}
+
+func (s sessionSetting) restore(ctx context.Context, tx *sql.Tx) error {
+	if !s.value.Valid {
+		_, err := tx.ExecContext(ctx, fmt.Sprintf("SET SESSION %s = DEFAULT", s.name))
+		return err
+	}
+	_, err := tx.ExecContext(ctx, fmt.Sprintf("SET SESSION %s = ?", s.name), s.value.String)
+	return err
+}
+
+// validateSettingName はセッション設定名がSQLに埋め込める形式か検証します
+func validateSettingName(name string) error {
+	if !settingNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid setting name: %q", name)
+	}
+	return nil
+}
+
+// writeSessionSettings はセッション設定を適用するステートメントをスクリプトに書き込みます
+func writeSessionSettings(b *strings.Builder, d Dialect, settings map[string]string) error {
+	for _, name := range sortedSettingNames(settings) {
+		if err := validateSettingName(name); err != nil {
+			return err
+		}
+		v := "'" + strings.ReplaceAll(settings[name], "'", "''") + "'"
+		switch d {
+		case DialectMySQL:
+			b.WriteString(fmt.Sprintf("SET SESSION %s = %s;\n", name, v))
+		default:
+			b.WriteString(fmt.Sprintf("SELECT set_config('%s', %s, true);\n", name, v))
+		}
+	}
+	return nil
+}
+
+func sortedSettingNames(settings map[string]string) []string {
+	names := make([]string, 0, len(settings))
+	for name := range settings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// useSchema はトランザクション内で、スキーマを指定しないテーブル名を schema のテーブルとして参照するようにします。
+//
+// PostgreSQLの場合は search_path をトランザクション内でのみ変更します。
+// MySQLの場合は変更前のデフォルトのデータベースを取得してから USE で変更します。
+// 返却する restore はトランザクションの終了前に呼び出し、MySQLの接続のデフォルトのデータベースを元に戻します。
+// MySQLでデフォルトのデータベースがない接続は元に戻せないため、スキーマを変更できません。
+func useSchema(ctx context.Context, tx *sql.Tx, d Dialect, schema string) (restore func() error, err error) {
+	restore = func() error { return nil }
+	if schema == "" {
+		return restore, nil
+	}
+	if !settingNamePattern.MatchString(schema) || strings.Contains(schema, ".") {
+		return restore, fmt.Errorf("invalid schema name: %q", schema)
+	}
+
+	switch d {
+	case DialectMySQL:
+		var previous sql.NullString
+		if err := tx.QueryRowContext(ctx, "SELECT DATABASE()").Scan(&previous); err != nil {
+			return restore, fmt.Errorf("get current database: %w", err)
+		}
+		if !previous.Valid {
+			return restore, fmt.Errorf("use schema %s: connection has no default database to restore", schema)
+		}
+		if _, err := tx.ExecContext(ctx, "USE "+schema); err != nil {
+			return restore, fmt.Errorf("use schema %s: %w", schema, err)
+		}
+		restored := false
+		restore = func() error {
+			if restored {
+				return nil
+			}
+			restored = true
+			// ctx がキャンセルされていても元に戻せるようにする
+			_, err := tx.ExecContext(context.Background(), "USE `"+strings.ReplaceAll(previous.String, "`", "``")+"`")
+			return err
+		}
+	default:
+		if _, err := tx.ExecContext(ctx, "SELECT set_config('search_path', $1, true)", schema); err != nil {
+			return restore, fmt.Errorf("use schema %s: %w", schema, err)
+		}
+	}
+	return restore, nil
+}
@@ -0,0 +1,122 @@
+package exceltesting
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fc-shota-miyazaki/go-exceltesting/testonly"
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_applySessionSettings(t *testing.T) {
+	conn := testonly.OpenTestDB(t)
+	t.Cleanup(func() { conn.Close() })
+
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		settings map[string]string
+		want     []string
+		wantErr  bool
+	}{
+		{
+			name:     "applied in transaction",
+			settings: map[string]string{"TimeZone": "UTC", "app.current_tenant": "42"},
+			want:     []string{"UTC", "42"},
+		},
+		{
+			name:     "invalid setting name",
+			settings: map[string]string{"TimeZone; DROP TABLE company": "UTC"},
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx, err := conn.BeginTx(ctx, nil)
+			if err != nil {
+				t.Fatalf("start transaction: %v", err)
+			}
+			defer tx.Rollback()
+
+			if _, err := applySessionSettings(ctx, tx, DialectPostgres, tt.settings); (err != nil) != tt.wantErr {
+				t.Fatalf("applySessionSettings() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			got := make([]string, 2)
+			if err := tx.QueryRowContext(ctx, `SELECT current_setting('TimeZone'), current_setting('app.current_tenant')`).Scan(&got[0], &got[1]); err != nil {
+				t.Fatalf("query settings: %v", err)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("settings mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_writeSessionSettings(t *testing.T) {
+	settings := map[string]string{"search_path": "tenant, public", "TimeZone": "Asia/Tokyo"}
+
+	tests := []struct {
+		name     string
+		dialect  Dialect
+		settings map[string]string
+		want     string
+		wantErr  bool
+	}{
+		{
+			name:     "postgres",
+			dialect:  DialectPostgres,
+			settings: settings,
+			want: "SELECT set_config('TimeZone', 'Asia/Tokyo', true);\n" +
+				"SELECT set_config('search_path', 'tenant, public', true);\n",
+		},
+		{
+			name:     "mysql",
+			dialect:  DialectMySQL,
+			settings: settings,
+			want: "SET SESSION TimeZone = 'Asia/Tokyo';\n" +
+				"SET SESSION search_path = 'tenant, public';\n",
+		},
+		{
+			name:     "invalid setting name",
+			dialect:  DialectPostgres,
+			settings: map[string]string{"x', 'y', true); DROP TABLE t; --": "UTC"},
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b strings.Builder
+			if err := writeSessionSettings(&b, tt.dialect, tt.settings); (err != nil) != tt.wantErr {
+				t.Fatalf("writeSessionSettings() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if diff := cmp.Diff(tt.want, b.String()); diff != "" {
+				t.Errorf("writeSessionSettings() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestDryRun_SessionSettings(t *testing.T) {
+	var b strings.Builder
+	err := DryRun(LoadRequest{
+		TargetBookPath:  filepath.Join("testdata", "load_example.xlsx"),
+		DryRun:          &b,
+		SessionSettings: map[string]string{"TimeZone": "UTC"},
+	})
+	if err != nil {
+		t.Fatalf("DryRun() error = %v", err)
+	}
+	if want := "BEGIN;\nSELECT set_config('TimeZone', 'UTC', true);\n"; !strings.HasPrefix(b.String(), want) {
+		t.Errorf("DryRun() should start with %q but %q", want, b.String())
+	}
+}
@@ -0,0 +1,140 @@
+package exceltesting
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+const (
+	// sqlSheetMarker はA2セルに記載することでSQLシートであることを示すマーカーです
+	sqlSheetMarker = "#sql"
+	// beforeHookMarker はテーブルシートのA列に記載することで、データ投入前に実行するSQLをB列に記載できます
+	beforeHookMarker = "#before"
+	// afterHookMarker はテーブルシートのA列に記載することで、データ投入後に実行するSQLをB列に記載できます
+	afterHookMarker = "#after"
+	// whereMarker はテーブルシートのA列に記載することで、比較対象の行を絞り込む条件をB列に記載できます
+	whereMarker = "#where"
+)
+
+// queryPattern はクエリシートのA2セルに記載するSELECT文 (WITH句を含む) の形式です
+var queryPattern = regexp.MustCompile(`(?is)^\s*(select|with)\s`)
+
+// isSQLSheet はシートがSQLステートメントを記載したSQLシートかどうかを判定します。
+// A2セルに "#sql" が記載されているか、シート名が prefix で始まる場合にSQLシートとみなします。
+func isSQLSheet(f *excelize.File, sheet, prefix string) bool {
+	if prefix != "" && strings.HasPrefix(sheet, prefix) {
+		return true
+	}
+	v, err := f.GetCellValue(sheet, "A2")
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(strings.TrimSpace(v), sqlSheetMarker)
+}
+
+// isQuery はA2セルの値がテーブル名ではなく、SELECT文であれば true を返します
+func isQuery(v string) bool {
+	return queryPattern.MatchString(v)
+}
+
+// isQuerySheet はA2セルにSELECT文を記載したクエリシートかどうかを判定します。
+// クエリシートはクエリの結果と比較するためのシートで、データ投入の対象になりません。
+func isQuerySheet(f *excelize.File, sheet string) bool {
+	v, err := f.GetCellValue(sheet, "A2")
+	if err != nil {
+		return false
+	}
+	return isQuery(v)
+}
+
+// loadSQLSheet はSQLシートのA列に記載されたSQLステートメントを上から順に返します。
+// 1行目はシートの説明として読み飛ばします。
+func loadSQLSheet(f *excelize.File, sheet string) ([]string, error) {
+	rows, err := f.GetRows(sheet)
+	if err != nil {
+		return nil, fmt.Errorf("get rows: %w", err)
+	}
+
+	var stmts []string
+	for i, row := range rows {
+		if i == 0 || len(row) == 0 {
+			continue
+		}
+		stmt := strings.TrimSpace(row[0])
+		if stmt == "" || strings.EqualFold(stmt, sqlSheetMarker) {
+			continue
+		}
+		stmts = append(stmts, stmt)
+	}
+	return stmts, nil
+}
+
+// getHookStatements はテーブルシートのカラム定義行より上に記載された、
+// データ投入前後に実行するSQLステートメントを返します。
+func getHookStatements(rows [][]string, columnDefineRowNum int) (before []string, after []string) {
+	for i, row := range rows {
+		if i >= columnDefineRowNum-1 {
+			break
+		}
+		if len(row) < 2 {
+			continue
+		}
+		stmt := strings.TrimSpace(row[1])
+		if stmt == "" {
+			continue
+		}
+		switch strings.ToLower(strings.TrimSpace(row[0])) {
+		case beforeHookMarker:
+			before = append(before, stmt)
+		case afterHookMarker:
+			after = append(after, stmt)
+		}
+	}
+	return before, after
+}
+
+// getWhereCondition はテーブルシートのカラム定義行より上に記載された、比較対象の行を絞り込む条件を返します。
+// 複数記載されている場合は AND で結合します。
+func getWhereCondition(rows [][]string, columnDefineRowNum int) string {
+	var conds []string
+	for i, row := range rows {
+		if i >= columnDefineRowNum-1 {
+			break
+		}
+		if len(row) < 2 || !strings.EqualFold(strings.TrimSpace(row[0]), whereMarker) {
+			continue
+		}
+		if cond := strings.TrimSpace(row[1]); cond != "" {
+			conds = append(conds, cond)
+		}
+	}
+	if len(conds) == 1 {
+		return conds[0]
+	}
+	for i, c := range conds {
+		conds[i] = "(" + c + ")"
+	}
+	return strings.Join(conds, " AND ")
+}
+
+// execStatements はSQLステートメントを順に実行します
+func execStatements(ctx context.Context, tx *sql.Tx, stmts []string) error {
+	for _, stmt := range stmts {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("exec %q: %w", stmt, err)
+		}
+	}
+	return nil
+}
+
+// writeStatements はSQLステートメントを終端の ";" を補ってスクリプトに書き込みます
+func writeStatements(b *strings.Builder, stmts []string) {
+	for _, stmt := range stmts {
+		b.WriteString(strings.TrimSuffix(stmt, ";") + ";\n")
+	}
+}
@@ -0,0 +1,25 @@
+package exceltesting
+
+import "testing"
+
+func Test_isQuery(t *testing.T) {
+	tests := []struct {
+		name string
+		v    string
+		want bool
+	}{
+		{name: "table name", v: "company", want: false},
+		{name: "table name starting with select", v: "selection", want: false},
+		{name: "select", v: "SELECT * FROM company", want: true},
+		{name: "lower case with newline", v: "select company_cd\nfrom company", want: true},
+		{name: "with", v: " WITH t AS (SELECT 1) SELECT * FROM t", want: true},
+		{name: "sql sheet marker", v: "#sql", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isQuery(tt.v); got != tt.want {
+				t.Errorf("isQuery(%q) = %v, want %v", tt.v, got, tt.want)
+			}
+		})
+	}
+}
@@ -14,41 +14,89 @@ var functionNames = []string{
 
 // table は投入対象のテーブルです
 type table struct {
+	sheet   string
 	name    string
 	columns []string
 	data    [][]string
+	// rowNums は data の各行に対応するA列の番号です
+	rowNums []int
+	// before, after はデータ投入の前後に実行するSQLステートメントです
+	before []string
+	after  []string
+	// where は比較対象の行を絞り込む条件です
+	where string
+	// query はクエリシートのA2セルに記載されたSELECT文です。テーブルシートの場合は空文字です
+	query string
+}
+
+// optionKey は CompareRequest のテーブルごとの設定を参照するキーです。
+// テーブルシートの場合はテーブル名、クエリシートの場合はシート名です。
+func (t *table) optionKey() string {
+	if t.query != "" {
+		return t.sheet
+	}
+	return t.name
 }
 
 // buildSQL はINSERTステートメントを作成します
 func (t *table) buildInsertSQL() string {
-	var valueSQLExp string
-	for j, row := range t.data {
-		rowSQLExp := "("
-		for i, cell := range row {
-			if i >= 1 {
-				rowSQLExp = fmt.Sprintf("%s, ", rowSQLExp)
-			}
-			v := strings.Trim(strings.Trim(cell, "　"), " ")
-			if v == "" || strings.EqualFold(v, "null") || strings.EqualFold(v, "<nil>") || strings.EqualFold(v, "(nil)") || strings.EqualFold(v, "nil") {
-				rowSQLExp += "null"
-			} else if slices.Contains(functionNames, v) {
-				rowSQLExp += v
-			} else {
-				rowSQLExp += fmt.Sprintf("'%s'", v)
-			}
-		}
-		rowSQLExp += ")"
-		if j == 0 {
-			valueSQLExp = rowSQLExp
-			continue
-		}
-		valueSQLExp = valueSQLExp + "," + rowSQLExp
+	// 行数が多い場合に文字列の連結で再確保を繰り返さないよう、まとめて結合する
+	rowSQLExpList := make([]string, 0, len(t.data))
+	for _, row := range t.data {
+		rowSQLExpList = append(rowSQLExpList, "("+strings.Join(rowSQLExps(row), ", ")+")")
 	}
 
-	sql := fmt.Sprintf("INSERT INTO %s (%s) VALUES%s;\n", t.name, t.sqlColumnExp(), valueSQLExp)
+	sql := fmt.Sprintf("%s%s;\n", t.insertSQLPrefix(), strings.Join(rowSQLExpList, ","))
 	return sql
 }
 
+// insertSQLPrefix はINSERTステートメントのVALUES句までを返します
+func (t *table) insertSQLPrefix() string {
+	return fmt.Sprintf("INSERT INTO %s (%s) VALUES", t.name, t.sqlColumnExp())
+}
+
+// rowSQLExps は行の各セルをSQLの値表現に変換します
+func rowSQLExps(row []string) []string {
+	exps := make([]string, 0, len(row))
+	for _, cell := range row {
+		exps = append(exps, cellSQLExp(cell))
+	}
+	return exps
+}
+
+func cellSQLExp(cell string) string {
+	v := strings.Trim(strings.Trim(cell, "　"), " ")
+	if v == "" || strings.EqualFold(v, "null") || strings.EqualFold(v, "<nil>") || strings.EqualFold(v, "(nil)") || strings.EqualFold(v, "nil") {
+		return "null"
+	}
+	if slices.Contains(functionNames, v) {
+		return v
+	}
+	return fmt.Sprintf("'%s'", v)
+}
+
+// row は i 行目のデータのみを持つテーブルを返します
+func (t *table) row(i int) *table {
+	r := &table{
+		sheet:   t.sheet,
+		name:    t.name,
+		columns: t.columns,
+		data:    t.data[i : i+1],
+	}
+	if i < len(t.rowNums) {
+		r.rowNums = t.rowNums[i : i+1]
+	}
+	return r
+}
+
+// rowNum は i 行目のA列の番号を返します
+func (t *table) rowNum(i int) int {
+	if i < len(t.rowNums) {
+		return t.rowNums[i]
+	}
+	return i + 1
+}
+
 func (t *table) sqlColumnExp() string {
 	return strings.Join(t.columns, ",")
 }
@@ -85,5 +133,9 @@ func (t table) DeepCopy() table {
 			}
 		}
 	}
+	if t.rowNums != nil {
+		cp.rowNums = make([]int, len(t.rowNums))
+		copy(cp.rowNums, t.rowNums)
+	}
 	return cp
 }
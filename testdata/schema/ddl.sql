@@ -56,3 +56,24 @@ DROP TABLE IF EXISTS temperature_2021_2022
 ;
 CREATE TABLE temperature_2021_2022 PARTITION OF temperature FOR VALUES FROM ('20210101') TO ('20220101')
 ;
+
+DROP TABLE IF EXISTS temperature_log_2022
+;
+DROP TABLE IF EXISTS temperature_log
+;
+CREATE TABLE temperature_log(
+    ymd varchar(8) NOT NULL,
+    value numeric(4,1) NOT NULL,
+    CONSTRAINT temperature_log_pkc PRIMARY KEY(ymd)
+)
+;
+CREATE TABLE temperature_log_2022() INHERITS (temperature_log)
+;
+
+DROP TABLE IF EXISTS audit_log
+;
+CREATE TABLE audit_log(
+    event varchar(32) NOT NULL,
+    company_cd varchar(5) NOT NULL
+)
+;
@@ -0,0 +1,61 @@
+package exceltesting
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+// testing.TB を実装する型は TestingT として渡せる
+var (
+	_ TestingT = (*testing.T)(nil)
+	_ TestingT = (*testing.B)(nil)
+	_ TestingT = (*testing.F)(nil)
+	_ TestingT = (testing.TB)(nil)
+)
+
+// fakeT は Ginkgo の GinkgoT() のような testing.TB 以外の TestingT の実装です
+type fakeT struct {
+	logs   []string
+	errors []string
+	fatals []string
+}
+
+func (f *fakeT) Helper() {}
+func (f *fakeT) Logf(format string, args ...any) {
+	f.logs = append(f.logs, fmt.Sprintf(format, args...))
+}
+func (f *fakeT) Errorf(format string, args ...any) {
+	f.errors = append(f.errors, fmt.Sprintf(format, args...))
+}
+func (f *fakeT) Fatalf(format string, args ...any) {
+	f.fatals = append(f.fatals, fmt.Sprintf(format, args...))
+}
+
+func Test_exceltesing_DumpCSV_TestingT(t *testing.T) {
+	e := &exceltesing{}
+	ft := &fakeT{}
+	e.DumpCSV(ft, DumpRequest{TargetBookPaths: []string{filepath.Join("testdata", "not_exists.xlsx")}})
+	if len(ft.errors) != 1 {
+		t.Errorf("DumpCSV() should report 1 error to TestingT, got %v", ft.errors)
+	}
+}
+
+func Test_reportCompareResult_UpdateLog(t *testing.T) {
+	ft := &fakeT{}
+	res := &CompareResult{
+		Updated: true,
+		Tables: []TableDiff{{
+			Table:        "company",
+			Sheet:        "会社",
+			ChangedCells: []CellDiff{{Row: 1, Column: "founded_year", Want: "1989", Got: "1990"}},
+		}},
+	}
+	if !reportCompareResult(ft, CompareRequest{TargetBookPath: "want.xlsx"}, res) {
+		t.Error("reportCompareResult() should return true for updated differences")
+	}
+	// 更新した差分は testing.TB 以外の TestingT にも記録する
+	if len(ft.logs) != 1 || len(ft.errors) != 0 {
+		t.Errorf("reportCompareResult() should log 1 update without errors, got logs %v, errors %v", ft.logs, ft.errors)
+	}
+}
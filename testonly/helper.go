@@ -23,7 +23,7 @@ func getDriverFromDSN(dsn string) string {
 	return "pgx"
 }
 
-func OpenTestDB(t *testing.T) *sql.DB {
+func OpenTestDB(t testing.TB) *sql.DB {
 	t.Helper()
 
 	// 環境変数から接続文字列を取得
@@ -49,7 +49,7 @@ func OpenTestDB(t *testing.T) *sql.DB {
 	return db
 }
 
-func ExecSQLFile(t *testing.T, db *sql.DB, filePath string) {
+func ExecSQLFile(t testing.TB, db *sql.DB, filePath string) {
 	t.Helper()
 
 	b, err := os.ReadFile(filePath)
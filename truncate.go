@@ -0,0 +1,111 @@
+package exceltesting
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+	"golang.org/x/exp/slices"
+)
+
+// mysqlForeignKeyChecksVariable はMySQLで関連するテーブルを空にする間、変更前の外部キーの検査の設定を保持する変数です
+const mysqlForeignKeyChecksVariable = "@exceltesting_foreign_key_checks"
+
+// relatedTables はBookの投入対象のテーブルと、それらを参照するテーブルのうち、外部キーで関連するテーブルを参照先が先になる順に返します。
+//
+// 外部キーで参照されているテーブルは1つずつ TRUNCATE できないため、LoadRequest.TruncateReferencingTables が指定された場合、
+// Load ではこれらのテーブルを先にまとめて空にします。投入対象のテーブルを参照するテーブルは、Bookに含まれなくても空にします。
+// 自身のみを参照するテーブルは1つずつ空にできるため含めません。
+func (e *exceltesing) relatedTables(ctx context.Context, q queryer, f *excelize.File, r LoadRequest) ([]string, error) {
+	var names []string
+	for _, sheet := range f.GetSheetList() {
+		if !isTargetSheet(sheet, r.SheetPrefix, r.IgnoreSheet) || isQuerySheet(f, sheet) || isSQLSheet(f, sheet, r.SQLSheetPrefix) {
+			continue
+		}
+		name, err := f.GetCellValue(sheet, "A2")
+		if err != nil {
+			return nil, fmt.Errorf("get table name, sheet = %s: %w", sheet, err)
+		}
+		if name != "" && !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	fks, err := detectDialect(e.db).introspector().foreignKeys(ctx, q)
+	if err != nil {
+		return nil, fmt.Errorf("get foreign keys: %w", err)
+	}
+	return relatedTablesOf(names, fks), nil
+}
+
+// relatedTablesOf は names のテーブルと、それらを参照するテーブルのうち、外部キーで関連するテーブルを参照先が先になる順に返します
+func relatedTablesOf(names []string, fks []foreignKey) []string {
+	tables := append([]string(nil), names...)
+	for added := true; added; {
+		added = false
+		for _, fk := range fks {
+			if slices.Contains(tables, fk.refTable) && !slices.Contains(tables, fk.table) {
+				tables = append(tables, fk.table)
+				added = true
+			}
+		}
+	}
+
+	var related []string
+	var edges []foreignKey
+	for _, fk := range fks {
+		if fk.table == fk.refTable || !slices.Contains(tables, fk.table) || !slices.Contains(tables, fk.refTable) {
+			continue
+		}
+		edges = append(edges, fk)
+		for _, name := range []string{fk.table, fk.refTable} {
+			if !slices.Contains(related, name) {
+				related = append(related, name)
+			}
+		}
+	}
+	return sortTablesByDependency(related, edges)
+}
+
+// truncateStatements は tables を外部キーの制約に違反せずにまとめて空にするステートメントを返します。
+//
+// PostgreSQLは1つの TRUNCATE で空にします。MySQLは複数のテーブルを1つの TRUNCATE で空にできないため、
+// 外部キーの検査を無効にしてから1つずつ空にし、最後のステートメントで検査の設定を元に戻します。
+func truncateStatements(d Dialect, tables []string) []string {
+	if len(tables) == 0 {
+		return nil
+	}
+	if d != DialectMySQL {
+		return []string{"TRUNCATE TABLE " + strings.Join(tables, ", ")}
+	}
+
+	stmts := []string{
+		fmt.Sprintf("SET %s = @@SESSION.foreign_key_checks", mysqlForeignKeyChecksVariable),
+		"SET SESSION foreign_key_checks = 0",
+	}
+	for _, t := range tables {
+		stmts = append(stmts, "TRUNCATE TABLE "+t)
+	}
+	return append(stmts, fmt.Sprintf("SET SESSION foreign_key_checks = %s", mysqlForeignKeyChecksVariable))
+}
+
+// truncateTables はトランザクション内で tables をまとめて空にします
+func truncateTables(ctx context.Context, tx *sql.Tx, d Dialect, tables []string) error {
+	stmts := truncateStatements(d, tables)
+	if d != DialectMySQL || len(stmts) == 0 {
+		return execStatements(ctx, tx, stmts)
+	}
+
+	// 失敗した場合も接続の外部キーの検査を元に戻す
+	restore := stmts[len(stmts)-1]
+	err := execStatements(ctx, tx, stmts[:len(stmts)-1])
+	if _, rerr := tx.ExecContext(context.Background(), restore); rerr != nil && err == nil {
+		err = fmt.Errorf("restore foreign_key_checks: %w", rerr)
+	}
+	return err
+}
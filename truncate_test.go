@@ -0,0 +1,86 @@
+package exceltesting
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func Test_relatedTablesOf(t *testing.T) {
+	fks := []foreignKey{
+		{table: "order_line", refTable: "orders"},
+		{table: "order_line", refTable: "product"},
+		{table: "orders", refTable: "customer"},
+		{table: "customer", refTable: "customer"},
+		{table: "category", refTable: "category"},
+	}
+	tests := []struct {
+		name  string
+		names []string
+		want  []string
+	}{
+		{
+			name:  "referencing tables not in book",
+			names: []string{"customer"},
+			want:  []string{"customer", "orders", "order_line"},
+		},
+		{
+			name:  "referenced tables in book",
+			names: []string{"order_line", "product"},
+			want:  []string{"product", "order_line"},
+		},
+		{
+			name:  "self reference only",
+			names: []string{"category"},
+			want:  nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := relatedTablesOf(tt.names, fks)
+			if diff := cmp.Diff(tt.want, got, cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("relatedTablesOf() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_truncateStatements(t *testing.T) {
+	tests := []struct {
+		name    string
+		dialect Dialect
+		tables  []string
+		want    []string
+	}{
+		{
+			name:    "postgres",
+			dialect: DialectPostgres,
+			tables:  []string{"customer", "orders"},
+			want:    []string{"TRUNCATE TABLE customer, orders"},
+		},
+		{
+			name:    "mysql",
+			dialect: DialectMySQL,
+			tables:  []string{"customer", "orders"},
+			want: []string{
+				"SET @exceltesting_foreign_key_checks = @@SESSION.foreign_key_checks",
+				"SET SESSION foreign_key_checks = 0",
+				"TRUNCATE TABLE customer",
+				"TRUNCATE TABLE orders",
+				"SET SESSION foreign_key_checks = @exceltesting_foreign_key_checks",
+			},
+		},
+		{
+			name:    "no tables",
+			dialect: DialectPostgres,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if diff := cmp.Diff(tt.want, truncateStatements(tt.dialect, tt.tables)); diff != "" {
+				t.Errorf("truncateStatements() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,182 @@
+package exceltesting
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/xuri/excelize/v2"
+	"golang.org/x/exp/slices"
+)
+
+// updateEnv は期待値のBookをデータベースの値で更新する環境変数です (e.g. EXCELTESTING_UPDATE=1 go test ./...)
+const updateEnv = "EXCELTESTING_UPDATE"
+
+// updateBook は比較結果 res の差分を期待値のBook book に反映して上書きします。
+//
+//   - 値が異なるセルはデータベースの値に書き換えます。マッチャーとして解釈される値は先頭に \ を付与します。
+//     マッチャーのセルは一致しない場合もそのまま残し、TableDiff.NotUpdatedCells に追加します
+//   - 期待値にのみ存在する行は削除します
+//   - データベースにのみ存在する行は、直前のデータ行の書式でデータ行の末尾に追加します
+//
+// ヘッダ行やコメント、書式、無視するカラムのセルは変更しません。
+// 更新したシートがある場合に true を返します。
+func updateBook(book string, res *CompareResult) (bool, error) {
+	var targets []int
+	for i, d := range res.Tables {
+		if !d.Equal() && d.Err == nil {
+			targets = append(targets, i)
+		}
+	}
+	if len(targets) == 0 {
+		return false, nil
+	}
+
+	f, err := excelize.OpenFile(book)
+	if err != nil {
+		return false, fmt.Errorf("open excel file: %w", err)
+	}
+	defer f.Close()
+
+	updated := false
+	for _, i := range targets {
+		d := &res.Tables[i]
+		changed, err := updateSheet(f, d)
+		if err != nil {
+			return false, fmt.Errorf("sheet = %s: %w", d.Sheet, err)
+		}
+		updated = updated || changed
+	}
+	if !updated {
+		return false, nil
+	}
+
+	if err := f.Save(); err != nil {
+		return false, fmt.Errorf("save excel file: %w", err)
+	}
+	return true, nil
+}
+
+// updateSheet は差分 d をシートに反映し、反映した場合に true を返します。
+// 一致しないマッチャーのセルは反映せずに d.NotUpdatedCells に追加します。
+func updateSheet(f *excelize.File, d *TableDiff) (bool, error) {
+	l, err := newSheetLayout(f, d.Sheet)
+	if err != nil {
+		return false, err
+	}
+
+	changed := false
+	for _, c := range d.ChangedCells {
+		cell, ok := l.cell(c.Row, c.Column)
+		if !ok {
+			continue
+		}
+		v, err := f.GetCellValue(d.Sheet, cell)
+		if err != nil {
+			return false, err
+		}
+		if m, _ := parseMatcher(v); m != nil {
+			d.NotUpdatedCells = append(d.NotUpdatedCells, c)
+			continue
+		}
+		if err := f.SetCellValue(d.Sheet, cell, escapeMatcher(c.Got)); err != nil {
+			return false, err
+		}
+		changed = true
+	}
+	if len(d.ExtraRows) > 0 || len(d.MissingRows) > 0 {
+		changed = true
+	}
+
+	// 追加する行は最後のデータ行の書式とA列の番号を引き継ぐ
+	lastLine, lastNum := 0, 0
+	for no, line := range l.lines {
+		if line > lastLine {
+			lastLine = line
+		}
+		if no > lastNum {
+			lastNum = no
+		}
+	}
+	styles := make([]int, l.lastColumn+1)
+	if lastLine > 0 {
+		for col := 1; col <= l.lastColumn; col++ {
+			cell, _ := excelize.CoordinatesToCellName(col, lastLine)
+			styles[col], _ = f.GetCellStyle(d.Sheet, cell)
+		}
+	}
+	for i, r := range d.ExtraRows {
+		line := l.lastLine + 1 + i
+		for col := 1; col <= l.lastColumn; col++ {
+			cell, _ := excelize.CoordinatesToCellName(col, line)
+			if styles[col] != 0 {
+				_ = f.SetCellStyle(d.Sheet, cell, cell, styles[col])
+			}
+		}
+		first, _ := excelize.CoordinatesToCellName(1, line)
+		if err := f.SetCellValue(d.Sheet, first, strconv.Itoa(lastNum+1+i)); err != nil {
+			return false, err
+		}
+		for _, c := range r.Cells {
+			col, ok := l.columns[c.Column]
+			if !ok {
+				continue
+			}
+			cell, _ := excelize.CoordinatesToCellName(col, line)
+			if err := f.SetCellValue(d.Sheet, cell, escapeMatcher(c.Value)); err != nil {
+				return false, err
+			}
+		}
+	}
+
+	var lines []int
+	for _, r := range d.MissingRows {
+		if line, ok := l.lines[r.Row]; ok {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) == 0 {
+		return changed, nil
+	}
+	slices.Sort(lines)
+
+	// 行を削除してもコメントの位置は移動しないため、後続の行にコメントがある場合は値のみを消去する。
+	// A列が空の行はデータ行として読み込まれない
+	if hasCommentBelow(f, d.Sheet, lines[0]) {
+		for _, line := range lines {
+			for col := 1; col <= l.lastColumn; col++ {
+				cell, _ := excelize.CoordinatesToCellName(col, line)
+				if err := f.SetCellValue(d.Sheet, cell, nil); err != nil {
+					return false, err
+				}
+			}
+		}
+		return changed, nil
+	}
+	// 行を削除すると後続の行がずれるため、下の行から削除する
+	for i := len(lines) - 1; i >= 0; i-- {
+		if err := f.RemoveRow(d.Sheet, lines[i]); err != nil {
+			return false, err
+		}
+	}
+	return changed, nil
+}
+
+// hasCommentBelow はシートの line 行目以降にコメントがある場合に true を返します
+func hasCommentBelow(f *excelize.File, sheet string, line int) bool {
+	for _, c := range f.GetComments()[sheet] {
+		if _, row, err := excelize.CellNameToCoordinates(c.Ref); err == nil && row >= line {
+			return true
+		}
+	}
+	return false
+}
+
+// isUpdate は期待値のBookを更新する場合に true を返します
+func isUpdate(r CompareRequest) bool {
+	if r.Update {
+		return true
+	}
+	update, _ := strconv.ParseBool(os.Getenv(updateEnv))
+	return update
+}
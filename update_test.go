@@ -0,0 +1,224 @@
+package exceltesting
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/xuri/excelize/v2"
+)
+
+func Test_updateBook(t *testing.T) {
+	tests := []struct {
+		name  string
+		book  string
+		res   *CompareResult
+		sheet string
+		want  [][]string
+		// wantNotUpdated は反映しないマッチャーのセルです
+		wantNotUpdated []CellDiff
+	}{
+		{
+			name: "v1",
+			book: "compare.xlsx",
+			res: &CompareResult{Tables: []TableDiff{{
+				Table: "company",
+				Sheet: "会社",
+				MissingRows: []Row{
+					{Row: 1, Cells: []Cell{{Column: "company_cd", Value: "00001"}}},
+				},
+				ExtraRows: []Row{
+					{Cells: []Cell{{Column: "company_cd", Value: "00003"}, {Column: "company_name", Value: "FutureOne"}, {Column: "founded_year", Value: "2002"}}},
+				},
+				ChangedCells: []CellDiff{
+					{Row: 2, Column: "founded_year", Want: "1972", Got: "1973"},
+				},
+			}}},
+			sheet: "会社",
+			want: [][]string{
+				{"2", "00002", "YDC", "1973", "current_timestamp", "current_timestamp", "1"},
+				{"3", "00003", "FutureOne", "2002"},
+			},
+		},
+		{
+			name: "v2",
+			book: "compare.xlsx",
+			res: &CompareResult{Tables: []TableDiff{{
+				Table: "temperature",
+				Sheet: "気温",
+				ChangedCells: []CellDiff{
+					{Row: 1, Column: "value", Want: "-2.0", Got: "-2.5"},
+				},
+			}}},
+			sheet: "気温",
+			want: [][]string{
+				{"1", "20210228", "-2.5"},
+				{"2", "20210831", "38.5"},
+			},
+		},
+		{
+			name: "一致しないマッチャーも残す",
+			book: "compare_matcher.xlsx",
+			res: &CompareResult{Tables: []TableDiff{{
+				Table: "company",
+				Sheet: "会社",
+				ExtraRows: []Row{
+					{Cells: []Cell{{Column: "company_cd", Value: "00003"}, {Column: "company_name", Value: "FutureOne"}, {Column: "founded_year", Value: "2002"}}},
+				},
+				ChangedCells: []CellDiff{
+					{Row: 2, Column: "founded_year", Want: "1972±0.5", Got: "1980"},
+				},
+			}}},
+			sheet: "会社",
+			want: [][]string{
+				{"1", "00001", "~^Fut", ">=1900", "<now±1h>", "<notnull>", "1±1"},
+				{"2", "00002", "<any>", "1972±0.5", "<notnull>", "<any>", "<2"},
+				{"3", "00003", "FutureOne", "2002"},
+			},
+			wantNotUpdated: []CellDiff{
+				{Row: 2, Column: "founded_year", Want: "1972±0.5", Got: "1980"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := copyBook(t, filepath.Join("testdata", tt.book))
+			before, err := excelize.OpenFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			header, err := before.GetRows(tt.sheet)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_ = before.Close()
+
+			updated, err := updateBook(path, tt.res)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !updated {
+				t.Error("updateBook() should return true")
+			}
+			if diff := cmp.Diff(tt.wantNotUpdated, tt.res.Tables[0].NotUpdatedCells); diff != "" {
+				t.Errorf("NotUpdatedCells mismatch (-want +got):\n%s", diff)
+			}
+
+			f, err := excelize.OpenFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+
+			l, err := newSheetLayout(f, tt.sheet)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rows, err := f.GetRows(tt.sheet)
+			if err != nil {
+				t.Fatal(err)
+			}
+			n := len(rows) - len(tt.want)
+			if diff := cmp.Diff(tt.want, rows[n:]); diff != "" {
+				t.Errorf("data rows mismatch (-want +got):\n%s", diff)
+			}
+			// ヘッダ行は変更しない
+			if diff := cmp.Diff(header[:n], rows[:n]); diff != "" {
+				t.Errorf("header rows mismatch (-want +got):\n%s", diff)
+			}
+			// 追加した行は直前の行の書式を引き継ぐ
+			for col := 1; col <= l.lastColumn; col++ {
+				prev, _ := excelize.CoordinatesToCellName(col, len(rows)-1)
+				last, _ := excelize.CoordinatesToCellName(col, len(rows))
+				s1, _ := f.GetCellStyle(tt.sheet, prev)
+				s2, _ := f.GetCellStyle(tt.sheet, last)
+				if s1 != s2 {
+					t.Errorf("style of %s = %d, want %d", last, s2, s1)
+				}
+			}
+		})
+	}
+}
+
+func Test_updateBook_NoDiff(t *testing.T) {
+	path := copyBook(t, filepath.Join("testdata", "compare.xlsx"))
+	res := &CompareResult{Tables: []TableDiff{
+		{Table: "company", Sheet: "会社"},
+		{Table: "temperature", Sheet: "気温", Err: os.ErrNotExist},
+	}}
+	updated, err := updateBook(path, res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated {
+		t.Error("updateBook() should return false when there are no differences")
+	}
+}
+
+func Test_updateBook_OnlyMatcher(t *testing.T) {
+	path := copyBook(t, filepath.Join("testdata", "compare_matcher.xlsx"))
+	res := &CompareResult{Tables: []TableDiff{{
+		Table: "company",
+		Sheet: "会社",
+		ChangedCells: []CellDiff{
+			{Row: 2, Column: "founded_year", Want: "1972±0.5", Got: "1980"},
+		},
+	}}}
+	updated, err := updateBook(path, res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated {
+		t.Error("updateBook() should return false when only matcher cells differ")
+	}
+	if len(res.Tables[0].NotUpdatedCells) != 1 {
+		t.Errorf("NotUpdatedCells = %v, want 1 cell", res.Tables[0].NotUpdatedCells)
+	}
+
+	// 一致しないマッチャーのセルは更新モードでも失敗として報告する
+	ft := &fakeT{}
+	res.Updated = true
+	if reportCompareResult(ft, CompareRequest{TargetBookPath: path}, res) {
+		t.Error("reportCompareResult() should return false for not updated matcher cells")
+	}
+	if len(ft.errors) != 1 {
+		t.Errorf("reportCompareResult() should report 1 error, got %v", ft.errors)
+	}
+}
+
+func copyBook(t *testing.T, src string) string {
+	t.Helper()
+	b, err := os.ReadFile(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), filepath.Base(src))
+	if err := os.WriteFile(path, b, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func Test_isUpdate(t *testing.T) {
+	tests := []struct {
+		name   string
+		update bool
+		env    string
+		want   bool
+	}{
+		{name: "not specified", want: false},
+		{name: "request", update: true, want: true},
+		{name: "env", env: "1", want: true},
+		{name: "env false", env: "false", want: false},
+		{name: "invalid env", env: "yes", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(updateEnv, tt.env)
+			if got := isUpdate(CompareRequest{Update: tt.update}); got != tt.want {
+				t.Errorf("isUpdate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}